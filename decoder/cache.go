@@ -0,0 +1,67 @@
+package decoder
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheBlocks is the default number of decompressed record blocks cached by an MDict.
+const DefaultCacheBlocks = 64
+
+// lru is a bounded LRU cache of decompressed blocks, keyed by the block index.
+// It's safe for concurrent use, since an MDict is shared by all the requests in the server mode.
+type lru[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[int]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   int
+	value V
+}
+
+func newLRU[V any](capacity int) *lru[V] {
+	return &lru[V]{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[int]*list.Element, capacity),
+	}
+}
+
+func (c *lru[V]) get(key int) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *lru[V]) add(key int, value V) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry[V]).value = value
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key, value})
+	for c.ll.Len() > c.capacity {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lru[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,62 @@
+package decoder
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_lru(t *testing.T) {
+	c := newLRU[string](2)
+	c.add(1, "a")
+	c.add(2, "b")
+	v, ok := c.get(1)
+	assert.True(t, ok)
+	assert.Equal(t, "a", v)
+	c.add(3, "c") // 2 is the least recently used one
+	_, ok = c.get(2)
+	assert.False(t, ok)
+	_, ok = c.get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.len())
+
+	disabled := newLRU[string](-1)
+	disabled.add(1, "a")
+	_, ok = disabled.get(1)
+	assert.False(t, ok)
+}
+
+func Test_ReadRecordBlocks(t *testing.T) {
+	var entries [][2]string
+	for i := 0; i < 50; i++ {
+		entries = append(entries, [2]string{fmt.Sprintf("word%02d", i), fmt.Sprintf("<p>the definition of word %d</p>", i)})
+	}
+	// every record spans 3~4 blocks
+	b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 8, recordBlockSize: 10}
+	name := b.build(t)
+
+	for _, cacheBlocks := range []int{0, 4, -1} {
+		m := MDict{CacheBlocks: cacheBlocks}
+		assert.Nil(t, m.Decode(name, true))
+		for _, e := range entries {
+			assert.Equal(t, e[1], mustGet(t, &m, e[0]))
+		}
+		// the last record runs to the end of the records
+		last, err := m.ReadAtOffset(len(entries) - 1)
+		assert.Nil(t, err)
+		assert.Equal(t, entries[len(entries)-1][1], string(last))
+		switch {
+		case cacheBlocks < 0:
+			assert.Equal(t, 0, m.recordCache.len())
+		case cacheBlocks == 0:
+			assert.Greater(t, len(m.recordBlockSizes), DefaultCacheBlocks)
+			assert.Equal(t, DefaultCacheBlocks, m.recordCache.len())
+		default:
+			assert.Equal(t, cacheBlocks, m.recordCache.len())
+		}
+		dict, err := m.DumpDict()
+		assert.Nil(t, err)
+		assert.Equal(t, len(entries), len(dict))
+	}
+}
@@ -0,0 +1,291 @@
+package decoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Default block sizes of an Encoder, they are the max decompressed sizes of the blocks.
+const (
+	DefaultKeyBlockSize    = 32 * 1024
+	DefaultRecordBlockSize = 64 * 1024
+)
+
+// Encoder writes MDX/MDD files of engine version 2.0, the key/record blocks are zlib compressed.
+// The files can be opened by Decode, as well as MDict and GoldenDict.
+type Encoder struct {
+	// Header is the metadata of the dictionary, such as Title, Description, StyleSheet and Encoding.
+	// The engine versions are always "2.0", and Encrypted must be empty or "No".
+	// Encoding can be "UTF-8" (the default) or "UTF-16" for MDX files, MDD files are always UTF-16.
+	Header Header
+	// KeyBlockSize and RecordBlockSize limit the decompressed size of each key/record block,
+	// DefaultKeyBlockSize and DefaultRecordBlockSize are used if they are 0.
+	KeyBlockSize    int
+	RecordBlockSize int
+}
+
+type entry struct {
+	key    string
+	record []byte
+}
+
+// WriteMDX writes the key/definition pairs of dict as an MDX file to w.
+func (e *Encoder) WriteMDX(w io.Writer, dict map[string]string) error {
+	header, err := e.header("Dictionary")
+	if err != nil {
+		return err
+	}
+	m := &MDict{t: ".mdx", header: header, version: 2.0, encoding: header.Encoding}
+	entries := make([]entry, 0, len(dict))
+	for k, v := range dict {
+		entries = append(entries, entry{k, m.encodeString(v)})
+	}
+	return e.write(w, m, "Dictionary", entries)
+}
+
+// WriteMDD writes all the files under dir as an MDD file to w,
+// the keys are the paths relative to dir, in the form of "\img\a.png".
+func (e *Encoder) WriteMDD(w io.Writer, dir string) error {
+	header, err := e.header("Library_Data")
+	if err != nil {
+		return err
+	}
+	m := &MDict{t: ".mdd", header: header, version: 2.0, encoding: "UTF-16"}
+	var entries []entry
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key := `\` + strings.ReplaceAll(filepath.ToSlash(rel), "/", `\`)
+		entries = append(entries, entry{key, data})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk %v err: %v", dir, err)
+	}
+	return e.write(w, m, "Library_Data", entries)
+}
+
+// header fills the default values of e.Header.
+func (e *Encoder) header(root string) (Header, error) {
+	h := e.Header
+	h.GeneratedByEngineVersion = "2.0"
+	h.RequiredEngineVersion = "2.0"
+	if h.Encrypted != "" && h.Encrypted != "No" {
+		return h, fmt.Errorf("%w: encrypted dictionaries are not supported by the encoder", ErrEncrypted)
+	}
+	h.Encrypted = "No"
+	if root == "Library_Data" {
+		h.Encoding = ""
+		h.Format = ""
+	} else {
+		switch strings.ToUpper(h.Encoding) {
+		case "", "UTF-8", "UTF8":
+			h.Encoding = "UTF-8"
+		case "UTF-16", "UTF16":
+			h.Encoding = "UTF-16"
+		default:
+			return h, fmt.Errorf("unsupported encoding %q, only UTF-8 and UTF-16 are supported", h.Encoding)
+		}
+		if h.Format == "" {
+			h.Format = "Html"
+		}
+	}
+	if h.CreationDate == "" {
+		h.CreationDate = time.Now().Format("2006-1-2")
+	}
+	if h.KeyCaseSensitive == "" {
+		h.KeyCaseSensitive = "No"
+	}
+	if h.StripKey == "" {
+		h.StripKey = "Yes"
+	}
+	return h, nil
+}
+
+// headerXML is the header string, which is UTF-16 encoded in the file.
+func headerXML(root string, h Header) string {
+	var b strings.Builder
+	b.WriteString("<" + root)
+	attrs := [][2]string{
+		{"GeneratedByEngineVersion", h.GeneratedByEngineVersion},
+		{"RequiredEngineVersion", h.RequiredEngineVersion},
+		{"Encrypted", h.Encrypted},
+		{"Encoding", h.Encoding},
+		{"Format", h.Format},
+		{"CreationDate", h.CreationDate},
+		{"Compact", h.Compact},
+		{"Compat", h.Compat},
+		{"KeyCaseSensitive", h.KeyCaseSensitive},
+		{"StripKey", h.StripKey},
+		{"Description", h.Description},
+		{"Title", h.Title},
+		{"DataSourceFormat", h.DataSourceFormat},
+		{"StyleSheet", h.StyleSheet},
+		{"RegisterBy", h.RegisterBy},
+		{"RegCode", h.RegCode},
+	}
+	for _, a := range attrs {
+		// the encoding of MDD files is left empty
+		if a[1] == "" && a[0] != "Encoding" {
+			continue
+		}
+		b.WriteString(" " + a[0] + `="`)
+		xml.EscapeText(&b, []byte(a[1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("/>\r\n\x00")
+	return b.String()
+}
+
+// encodeString is the reverse of decodeString.
+func (m *MDict) encodeString(s string) []byte {
+	if m.encoding == "UTF-16" {
+		return encodeUTF16(s)
+	}
+	return []byte(s)
+}
+
+// encodeUTF16 encodes s in UTF-16LE.
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// compressBlock packs raw into a zlib compressed block, with the compression type and the checksum ahead.
+func compressBlock(raw []byte) ([]byte, error) {
+	var b bytes.Buffer
+	b.Write([]byte{2, 0, 0, 0})
+	binary.Write(&b, binary.BigEndian, adler32.Checksum(raw))
+	z := zlib.NewWriter(&b)
+	if _, err := z.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// write writes the entries in the MDX/MDD layout, root is the root element of the header.
+func (e *Encoder) write(w io.Writer, m *MDict, root string, entries []entry) error {
+	keyBlockSize, recordBlockSize := e.KeyBlockSize, e.RecordBlockSize
+	if keyBlockSize <= 0 {
+		keyBlockSize = DefaultKeyBlockSize
+	}
+	if recordBlockSize <= 0 {
+		recordBlockSize = DefaultRecordBlockSize
+	}
+	// the keys are sorted the same way as they are searched, see MDict.sortKey
+	sort.SliceStable(entries, func(i, j int) bool {
+		ki, kj := m.sortKey(entries[i].key), m.sortKey(entries[j].key)
+		if ki != kj {
+			return ki < kj
+		}
+		return entries[i].key < entries[j].key
+	})
+	terminator := m.encodeString("\x00")
+
+	var out bytes.Buffer
+	// header
+	headerBytes := encodeUTF16(headerXML(root, m.header))
+	binary.Write(&out, binary.BigEndian, uint32(len(headerBytes)))
+	out.Write(headerBytes)
+	binary.Write(&out, binary.LittleEndian, adler32.Checksum(headerBytes))
+
+	// key blocks, each key is its record offset followed by the null-terminated key
+	var keyIndex, keyBlocks bytes.Buffer
+	numKeyBlocks := 0
+	var offset uint64
+	for i := 0; i < len(entries); {
+		var raw bytes.Buffer
+		j := i
+		for ; j < len(entries) && (j == i || raw.Len() < keyBlockSize); j++ {
+			binary.Write(&raw, binary.BigEndian, offset)
+			raw.Write(m.encodeString(entries[j].key))
+			raw.Write(terminator)
+			offset += uint64(len(entries[j].record))
+		}
+		block, err := compressBlock(raw.Bytes())
+		if err != nil {
+			return err
+		}
+		keyBlocks.Write(block)
+		numKeyBlocks++
+
+		binary.Write(&keyIndex, binary.BigEndian, uint64(j-i))
+		for _, word := range []string{entries[i].key, entries[j-1].key} {
+			b := m.encodeString(word)
+			binary.Write(&keyIndex, binary.BigEndian, uint16(len(b)/len(terminator)))
+			keyIndex.Write(b)
+			keyIndex.Write(terminator)
+		}
+		binary.Write(&keyIndex, binary.BigEndian, uint64(len(block)))
+		binary.Write(&keyIndex, binary.BigEndian, uint64(raw.Len()))
+		i = j
+	}
+	compressedKeyIndex, err := compressBlock(keyIndex.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// keyword section
+	var keywordHeader bytes.Buffer
+	for _, n := range []int{numKeyBlocks, len(entries), keyIndex.Len(), len(compressedKeyIndex), keyBlocks.Len()} {
+		binary.Write(&keywordHeader, binary.BigEndian, uint64(n))
+	}
+	out.Write(keywordHeader.Bytes())
+	binary.Write(&out, binary.BigEndian, adler32.Checksum(keywordHeader.Bytes()))
+	out.Write(compressedKeyIndex)
+	out.Write(keyBlocks.Bytes())
+
+	// record section, a record block contains whole records only
+	var recordIndex, recordBlocks bytes.Buffer
+	numRecordBlocks := 0
+	for i := 0; i < len(entries); {
+		var raw bytes.Buffer
+		for j := i; i < len(entries) && (i == j || raw.Len()+len(entries[i].record) <= recordBlockSize); i++ {
+			raw.Write(entries[i].record)
+		}
+		block, err := compressBlock(raw.Bytes())
+		if err != nil {
+			return err
+		}
+		recordBlocks.Write(block)
+		numRecordBlocks++
+		binary.Write(&recordIndex, binary.BigEndian, uint64(len(block)))
+		binary.Write(&recordIndex, binary.BigEndian, uint64(raw.Len()))
+	}
+	for _, n := range []int{numRecordBlocks, len(entries), recordIndex.Len(), recordBlocks.Len()} {
+		binary.Write(&out, binary.BigEndian, uint64(n))
+	}
+	out.Write(recordIndex.Bytes())
+	out.Write(recordBlocks.Bytes())
+
+	_, err = out.WriteTo(w)
+	return err
+}
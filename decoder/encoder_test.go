@@ -0,0 +1,112 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EncodeMDX(t *testing.T) {
+	dict := map[string]string{
+		"apple":     "<b>apple</b> a fruit",
+		"Apple":     "a company",
+		"a-b":       "from a to b",
+		"tormenter": "@@@LINK=tormentor",
+		"tormentor": "someone who torments",
+		"empty":     "",
+		"中文":        "<p>Chinese</p>",
+	}
+	for i := 0; i < 300; i++ {
+		dict[fmt.Sprintf("word%03d", i)] = fmt.Sprintf("<p>definition of word %d</p>", i)
+	}
+	for _, encoding := range []string{"UTF-8", "UTF-16"} {
+		t.Run(encoding, func(t *testing.T) {
+			e := Encoder{
+				Header: Header{
+					Title:       "Team Glossary",
+					Description: `<p>"ondict" & friends</p>`,
+					StyleSheet:  "1\n<b>\n</b>",
+					Encoding:    encoding,
+				},
+				KeyBlockSize:    256,
+				RecordBlockSize: 512,
+			}
+			var out bytes.Buffer
+			assert.Nil(t, e.WriteMDX(&out, dict))
+			name := filepath.Join(t.TempDir(), "glossary.mdx")
+			assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+
+			for _, lookup := range []LookupMode{LookupKeymap, LookupIndex} {
+				m := MDict{Lookup: lookup}
+				assert.Nil(t, m.Decode(name, true))
+				assert.Greater(t, len(m.keyBlocks), 1)
+				assert.Greater(t, len(m.recordBlockSizes), 1)
+				assert.Equal(t, "Team Glossary", m.header.Title)
+				assert.Equal(t, `<p>"ondict" & friends</p>`, m.header.Description)
+				assert.Equal(t, "1\n<b>\n</b>", m.header.StyleSheet)
+				assert.Equal(t, encoding, m.header.Encoding)
+				assert.ElementsMatch(t, keysOf(dict), m.Keys())
+				for k, v := range dict {
+					if target, ok := LinkTarget(v); ok {
+						v = dict[target]
+					}
+					assert.Equal(t, v, mustGet(t, &m, k), k)
+				}
+				d, err := m.DumpDict()
+				assert.Nil(t, err)
+				assert.Equal(t, dict, d)
+			}
+		})
+	}
+}
+
+func Test_EncodeMDD(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.css":       []byte("body { color: red; }"),
+		"img/b.png":   bytes.Repeat([]byte{0x89, 'P', 'N', 'G', 0}, 100),
+		"snd/c/d.mp3": bytes.Repeat([]byte("ID3"), 1000),
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		assert.Nil(t, os.WriteFile(name, data, 0o644))
+	}
+	e := Encoder{Header: Header{Title: "Team Glossary"}, RecordBlockSize: 1024}
+	var out bytes.Buffer
+	assert.Nil(t, e.WriteMDD(&out, dir))
+	name := filepath.Join(t.TempDir(), "glossary.mdd")
+	assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+
+	m := MDict{}
+	assert.Nil(t, m.Decode(name, true))
+	assert.ElementsMatch(t, []string{`\a.css`, `\img\b.png`, `\snd\c\d.mp3`}, m.Keys())
+	for key, path := range map[string]string{`\a.css`: "a.css", `\img\b.png`: "img/b.png", `\snd\c\d.mp3`: "snd/c/d.mp3"} {
+		indexes, err := m.lookup(key)
+		assert.Nil(t, err)
+		assert.Len(t, indexes, 1)
+		data, err := m.ReadAtOffset(indexes[0])
+		assert.Nil(t, err)
+		assert.Equal(t, files[path], data, key)
+	}
+}
+
+func Test_EncodeUnsupported(t *testing.T) {
+	var out bytes.Buffer
+	e := Encoder{Header: Header{Encoding: "GBK"}}
+	assert.NotNil(t, e.WriteMDX(&out, map[string]string{"a": "b"}))
+	e = Encoder{Header: Header{Encrypted: "2"}}
+	assert.ErrorIs(t, e.WriteMDX(&out, map[string]string{"a": "b"}), ErrEncrypted)
+}
+
+func keysOf(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
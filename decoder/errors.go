@@ -0,0 +1,16 @@
+package decoder
+
+import "errors"
+
+var (
+	// ErrChecksum means a checksum in the file does not match its content.
+	ErrChecksum = errors.New("checksum mismatch")
+	// ErrUnsupportedVersion means the file was generated by an engine version which is not supported yet.
+	ErrUnsupportedVersion = errors.New("unsupported engine version")
+	// ErrCorrupt means the file is malformed, such as bad lengths, offsets or compressed data.
+	ErrCorrupt = errors.New("corrupt dictionary")
+	// ErrNotFound means there is no such key or resource in the dictionary.
+	ErrNotFound = errors.New("not found")
+	// ErrEncrypted means the dictionary is registered, but no valid UserID/RegCode is provided.
+	ErrEncrypted = errors.New("encrypted dictionary")
+)
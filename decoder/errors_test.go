@@ -0,0 +1,90 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DecodeCorrupt(t *testing.T) {
+	entries := [][2]string{
+		{"apple", "a fruit"},
+		{"broken", "the record block of it is broken"},
+		{"zoo", "a place where animals are kept"},
+	}
+	// the checksums of the blocks containing "broken", except the ones containing "apple", are wrong
+	badChecksum := func(raw []byte) []byte {
+		var w bytes.Buffer
+		w.Write([]byte{0, 0, 0, 0})
+		sum := adler32.Checksum(raw)
+		if bytes.Contains(raw, []byte("broken")) && !bytes.Contains(raw, []byte("apple")) {
+			sum++
+		}
+		binary.Write(&w, binary.BigEndian, sum)
+		w.Write(raw)
+		return w.Bytes()
+	}
+
+	t.Run("record block", func(t *testing.T) {
+		// the key block contains "apple" as well, so only the record block is broken
+		b := mdxBuilder{version: "2.0", entries: entries, recordBlockSize: 20, blocks: badChecksum}
+		name := b.build(t)
+		m := MDict{}
+		assert.ErrorIs(t, m.Decode(name, false), ErrChecksum)
+
+		m = MDict{}
+		assert.Nil(t, m.Decode(name, true))
+		def, err := m.Get("apple")
+		assert.Nil(t, err)
+		assert.Equal(t, "a fruit", def)
+		_, err = m.Get("broken")
+		assert.ErrorIs(t, err, ErrChecksum)
+		_, err = m.DumpDict()
+		assert.ErrorIs(t, err, ErrChecksum)
+	})
+
+	t.Run("key block", func(t *testing.T) {
+		b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 1, blocks: badChecksum}
+		name := b.build(t)
+		m := MDict{}
+		assert.ErrorIs(t, m.Decode(name, true), ErrChecksum)
+		m = MDict{Lookup: LookupIndex}
+		assert.Nil(t, m.Decode(name, true))
+		_, err := m.Get("broken")
+		assert.ErrorIs(t, err, ErrChecksum)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		b := mdxBuilder{version: "2.0", entries: entries}
+		data, err := os.ReadFile(b.build(t))
+		assert.Nil(t, err)
+		for _, n := range []int{0, 3, 100, len(data) / 2, len(data) - 1} {
+			name := filepath.Join(t.TempDir(), "truncated.mdx")
+			assert.Nil(t, os.WriteFile(name, data[:n], 0o644))
+			m := MDict{}
+			assert.NotNil(t, m.Decode(name, false), n)
+		}
+	})
+
+	t.Run("huge counts", func(t *testing.T) {
+		m := MDict{version: 2.0, numEntries: 1, fileSize: 100}
+		_, err := m.splitKeyBlock(make([]byte, 16), 1<<40)
+		assert.ErrorIs(t, err, ErrCorrupt)
+		// the index len of so many blocks overflows to 0
+		var section bytes.Buffer
+		binary.Write(&section, binary.BigEndian, []uint64{1 << 60, 1, 0, 0})
+		assert.ErrorIs(t, m.decodeRecordSection(&section, true), ErrCorrupt)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "garbage.mdx")
+		assert.Nil(t, os.WriteFile(name, bytes.Repeat([]byte("garbage!"), 100), 0o644))
+		m := MDict{}
+		assert.ErrorIs(t, m.Decode(name, true), ErrCorrupt)
+	})
+}
@@ -0,0 +1,253 @@
+package decoder
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Entry is a key with its record, the record is raw bytes, see MDict.Export.
+type Entry struct {
+	Index  int // the index of the key in the dictionary
+	Key    string
+	Record []byte
+}
+
+// ExportOptions controls how MDict.Export walks the dictionary.
+type ExportOptions struct {
+	// Workers is the number of goroutines decompressing record blocks, runtime.NumCPU() is used if it's 0.
+	Workers int
+	// Progress is called with the number of entries done so far, including the skipped ones,
+	// and the total number of entries. The calls are serialized.
+	Progress func(done, total int)
+	// Skip reports whether the entry of key, whose record has size bytes, can be skipped,
+	// e.g. it has been exported before. A record block isn't even decompressed if all of its entries are skipped.
+	Skip func(key string, size uint64) bool
+}
+
+// exportChunk is a run of record blocks and the keys whose records are in them,
+// no record spans two chunks, so each record block is decompressed exactly once.
+type exportChunk struct {
+	firstBlock, lastBlock int
+	firstKey, lastKey     int // keys[firstKey:lastKey]
+}
+
+// Export walks all the entries, fn is called for each entry concurrently from the workers,
+// in no particular order. The records of MDX files are StyleSheet-substituted if it's enabled.
+// It stops at the first error returned by fn or the decoder.
+func (m *MDict) Export(opts ExportOptions, fn func(Entry) error) error {
+	keys, err := m.allKeys()
+	if err != nil {
+		return err
+	}
+	blocks := m.recordBlockSizes
+	var totalSize uint64
+	if len(blocks) > 0 {
+		last := blocks[len(blocks)-1]
+		totalSize = last.decompOffset + last.DecompSize
+	}
+	// the block containing offset
+	blockOf := func(offset uint64) int {
+		return sort.Search(len(blocks), func(i int) bool {
+			return blocks[i].decompOffset+blocks[i].DecompSize > offset
+		})
+	}
+	recordRange := func(i int) (uint64, uint64, error) {
+		start, end := keys[i].offset, recordEnd(keys, i)
+		if end == math.MaxUint64 {
+			end = totalSize
+		}
+		if start > end || end > totalSize {
+			return 0, 0, fmt.Errorf("%w: bad record range [%d, %d) of key %d", ErrCorrupt, start, end, i)
+		}
+		return start, end, nil
+	}
+
+	var chunks []exportChunk
+	for i := 0; i < len(keys); {
+		start, _, err := recordRange(i)
+		if err != nil {
+			return err
+		}
+		c := exportChunk{firstBlock: blockOf(start), firstKey: i}
+		c.lastBlock = c.firstBlock
+		for ; i < len(keys); i++ {
+			start, end, err := recordRange(i)
+			if err != nil {
+				return err
+			}
+			if b := blockOf(start); b > c.lastBlock && start < totalSize {
+				break
+			}
+			if end > start {
+				if b := blockOf(end - 1); b > c.lastBlock {
+					c.lastBlock = b
+				}
+			}
+		}
+		c.lastKey = i
+		chunks = append(chunks, c)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	var (
+		mu       sync.Mutex // owns done and firstErr
+		done     int
+		firstErr error
+	)
+	progress := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		done += n
+		if opts.Progress != nil {
+			opts.Progress(done, len(keys))
+		}
+	}
+	failed := func(err error) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return firstErr != nil
+	}
+
+	export := func(c exportChunk) error {
+		names := make([]string, c.lastKey-c.firstKey)
+		skipped := make([]bool, len(names))
+		all := true
+		for i := range names {
+			k := c.firstKey + i
+			names[i] = m.decodeString(keys[k].key)
+			if opts.Skip != nil {
+				start, end, _ := recordRange(k)
+				skipped[i] = opts.Skip(names[i], end-start)
+			}
+			all = all && skipped[i]
+		}
+		if all {
+			progress(len(names))
+			return nil
+		}
+		var buf []byte
+		for b := c.firstBlock; b <= c.lastBlock && b < len(blocks); b++ {
+			decompressed, err := m.readRecordBlock(b)
+			if err != nil {
+				return err
+			}
+			buf = append(buf, decompressed...)
+		}
+		var base uint64
+		if c.firstBlock < len(blocks) {
+			base = blocks[c.firstBlock].decompOffset
+		}
+		for i, name := range names {
+			if skipped[i] {
+				progress(1)
+				continue
+			}
+			k := c.firstKey + i
+			start, end, _ := recordRange(k)
+			if end-base > uint64(len(buf)) {
+				return fmt.Errorf("%w: the record of key %d is out of the record blocks", ErrCorrupt, k)
+			}
+			record := buf[start-base : end-base : end-base]
+			if err := fn(Entry{Index: k, Key: name, Record: m.styled(record)}); err != nil {
+				return err
+			}
+			progress(1)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		log.Debugf("export %d entries in %d chunks with %d workers, cost: %v", len(keys), len(chunks), workers, time.Since(start))
+	}()
+	ch := make(chan exportChunk)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range ch {
+				if failed(nil) {
+					continue
+				}
+				failed(export(c))
+			}
+		}()
+	}
+	for _, c := range chunks {
+		if failed(nil) {
+			break
+		}
+		ch <- c
+	}
+	close(ch)
+	wg.Wait()
+	return firstErr
+}
+
+// DumpDataTo dumps all the resources in the MDD file to dir, e.g. "\img\a.png" to "<dir>/img/a.png".
+// The files existing with the same size are skipped, so a partially finished dump can be resumed.
+func (m *MDict) DumpDataTo(dir string, opts ExportOptions) error {
+	if m.t != ".mdd" {
+		return fmt.Errorf("The dict should be the MDD file, not %v", m.t)
+	}
+	start := time.Now()
+	defer func() {
+		log.Debugf("dump data cost: %v", time.Since(start))
+	}()
+	fileName := func(key string) (string, bool) {
+		// strip the leading "\"
+		if !strings.HasPrefix(key, `\`) {
+			return "", false
+		}
+		name := filepath.FromSlash(strings.ReplaceAll(key[1:], `\`, "/"))
+		if !filepath.IsLocal(name) {
+			return "", false
+		}
+		return filepath.Join(dir, name), true
+	}
+	skip := opts.Skip
+	opts.Skip = func(key string, size uint64) bool {
+		if skip != nil && skip(key, size) {
+			return true
+		}
+		name, ok := fileName(key)
+		if !ok {
+			log.Warnf("illegal fname: %q", key)
+			return true
+		}
+		stat, err := os.Stat(name)
+		return err == nil && stat.Mode().IsRegular() && uint64(stat.Size()) == size
+	}
+	return m.Export(opts, func(e Entry) error {
+		name, _ := fileName(e.Key)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return fmt.Errorf("make dir for %v err: %v", name, err)
+		}
+		// write to a temporary file first, so an interrupted write is never taken as done
+		tmp := name + ".tmp"
+		if err := os.WriteFile(tmp, e.Record, 0o644); err != nil {
+			return fmt.Errorf("write %v err: %v", name, err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			return fmt.Errorf("write %v err: %v", name, err)
+		}
+		log.Tracef("DumpData [%d] to file: %v", e.Index, name)
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Export(t *testing.T) {
+	var entries [][2]string
+	for i := 0; i < 100; i++ {
+		entries = append(entries, [2]string{fmt.Sprintf("word%03d", i), fmt.Sprintf("<p>the definition of word %d</p>", i)})
+	}
+	entries = append(entries, [2]string{"zzz", ""})
+	// records span 2~4 blocks
+	b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 9, recordBlockSize: 13}
+	name := b.build(t)
+
+	for _, lookup := range []LookupMode{LookupKeymap, LookupIndex} {
+		m := MDict{Lookup: lookup}
+		assert.Nil(t, m.Decode(name, true))
+		var mu sync.Mutex
+		got := make(map[string]string)
+		last := 0
+		err := m.Export(ExportOptions{
+			Workers: 4,
+			Progress: func(done, total int) {
+				assert.Equal(t, len(entries), total)
+				assert.Greater(t, done, last)
+				last = done
+			},
+		}, func(e Entry) error {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, entries[e.Index][0], e.Key)
+			got[e.Key] = string(e.Record)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, len(entries), last)
+		assert.Equal(t, len(entries), len(got))
+		for _, e := range entries {
+			assert.Equal(t, e[1], got[e[0]])
+		}
+		// the cache is bypassed
+		assert.Equal(t, 0, m.recordCache.len())
+
+		stop := errors.New("stop")
+		assert.ErrorIs(t, m.Export(ExportOptions{}, func(e Entry) error { return stop }), stop)
+	}
+}
+
+func Test_DumpDataTo(t *testing.T) {
+	res := t.TempDir()
+	files := map[string][]byte{
+		"a.css":       []byte("body { color: red; }"),
+		"img/b.png":   bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 100),
+		"snd/c/d.mp3": bytes.Repeat([]byte("ID3"), 300),
+	}
+	for name, data := range files {
+		name = filepath.Join(res, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		assert.Nil(t, os.WriteFile(name, data, 0o644))
+	}
+	e := Encoder{RecordBlockSize: 128}
+	var out bytes.Buffer
+	assert.Nil(t, e.WriteMDD(&out, res))
+	name := filepath.Join(t.TempDir(), "test.mdd")
+	assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+
+	m := MDict{}
+	assert.Nil(t, m.Decode(name, true))
+	dir := t.TempDir()
+	assert.Nil(t, m.DumpDataTo(dir, ExportOptions{}))
+	for name, data := range files {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.Nil(t, err)
+		assert.Equal(t, data, got, name)
+	}
+
+	// resume: a.css is missing, b.png is truncated, and d.mp3 is "done" with the right size
+	assert.Nil(t, os.Remove(filepath.Join(dir, "a.css")))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "img", "b.png"), []byte("half"), 0o644))
+	done := bytes.Repeat([]byte("x"), len(files["snd/c/d.mp3"]))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "snd", "c", "d.mp3"), done, 0o644))
+	var progress int
+	assert.Nil(t, m.DumpDataTo(dir, ExportOptions{Progress: func(n, total int) { progress = n }}))
+	assert.Equal(t, len(files), progress)
+	for name, want := range map[string][]byte{"a.css": files["a.css"], "img/b.png": files["img/b.png"], "snd/c/d.mp3": done} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, name)
+	}
+}
+
+func Test_DumpDictDuplicates(t *testing.T) {
+	entries := [][2]string{{"a", "0123456789"}}
+	for i := 0; i < 200; i++ {
+		entries = append(entries, [2]string{"dup", fmt.Sprintf("definition %03d", i)})
+	}
+	entries = append(entries, [2]string{"z", "0123456789"})
+	// every record is in its own block, so the duplicated keys are exported by different workers
+	b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 2, recordBlockSize: 1}
+	name := b.build(t)
+	m := MDict{}
+	assert.Nil(t, m.Decode(name, true))
+	keys, err := m.allKeys()
+	assert.Nil(t, err)
+	blockOf := func(offset uint64) int {
+		for i, b := range m.recordBlockSizes {
+			if offset < b.decompOffset+b.DecompSize {
+				return i
+			}
+		}
+		return len(m.recordBlockSizes)
+	}
+	assert.Less(t, blockOf(keys[2].offset-1), blockOf(keys[2].offset), "the records of dup are in different blocks")
+
+	for i := 0; i < 50; i++ {
+		d, err := m.DumpDict()
+		assert.Nil(t, err)
+		assert.Equal(t, "definition 199", d["dup"], "the last one wins")
+	}
+}
@@ -0,0 +1,199 @@
+package decoder
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LookupMode decides how the keys of an MDict are kept in memory.
+type LookupMode int
+
+const (
+	// LookupKeymap decodes all the keys when loading, and keeps them in a map.
+	// It's the fastest, but the memory usage grows with the size of the dictionary.
+	LookupKeymap LookupMode = iota
+	// LookupIndex only keeps the key block index, i.e. the first/last words of each key block.
+	// A key block is decompressed on demand and binary-searched when looking up a word,
+	// so the memory usage is independent of the size of the dictionary.
+	// MDict.Decode falls back to LookupKeymap if the keys are not sorted as expected, see MDict.sortKey.
+	LookupIndex
+)
+
+// keyBlock is an entry of the key block index.
+type keyBlock struct {
+	comp       uint64
+	decomp     uint64
+	numEntries uint64
+	firstWord  string
+	lastWord   string
+	fileOffset int64 // where the compressed block starts in the file
+	firstIndex int   // the index of its first key among all the keys
+}
+
+// decodedKeyBlock is a decompressed and split key block.
+type decodedKeyBlock struct {
+	keys   []keyOffset
+	sorted bool // whether the keys are in the order of sortKey, they are searched linearly otherwise
+}
+
+// keyCacheBlocks is the number of decoded key blocks cached in LookupIndex mode.
+const keyCacheBlocks = 16
+
+// stripKeyChars are ignored when sorting keys, if "StripKey" is enabled
+const stripKeyChars = "()., '/\\@_-"
+
+// sortKey normalizes key the way the keys are sorted in MDX files, which are case-insensitive
+// unless "KeyCaseSensitive" is "Yes", and punctuations are ignored unless "StripKey" is "No".
+func (m *MDict) sortKey(key string) string {
+	if !strings.EqualFold(m.header.KeyCaseSensitive, "Yes") {
+		key = strings.ToLower(key)
+	}
+	if !strings.EqualFold(m.header.StripKey, "No") {
+		key = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(stripKeyChars, r) {
+				return -1
+			}
+			return r
+		}, key)
+	}
+	return key
+}
+
+// indexSorted reports whether the first/last words of the key blocks are in the order of sortKey,
+// which searchIndexFunc relies on. The order of the keys inside a block is checked when it's read, see readKeyBlock.
+func (m *MDict) indexSorted() bool {
+	prev := ""
+	for _, b := range m.keyBlocks {
+		first, last := m.sortKey(b.firstWord), m.sortKey(b.lastWord)
+		if first < prev || last < first {
+			return false
+		}
+		prev = last
+	}
+	return true
+}
+
+// readKeyBlock reads and splits the ith key block.
+func (m *MDict) readKeyBlock(i int) (decodedKeyBlock, error) {
+	if d, ok := m.keyCache.get(i); ok {
+		return d, nil
+	}
+	b := m.keyBlocks[i]
+	compressed := make([]byte, b.comp)
+	if _, err := m.file.ReadAt(compressed, b.fileOffset); err != nil {
+		return decodedKeyBlock{}, fmt.Errorf("%w: read key block %d: %v", ErrCorrupt, i, err)
+	}
+	decompressed, err := decompressBlock(compressed, b.decomp)
+	if err != nil {
+		return decodedKeyBlock{}, fmt.Errorf("key block %d: %w", i, err)
+	}
+	keys, err := m.splitKeyBlock(decompressed, int(b.numEntries))
+	if err != nil {
+		return decodedKeyBlock{}, err
+	}
+	d := decodedKeyBlock{keys: keys, sorted: true}
+	for j := 1; j < len(keys); j++ {
+		if m.sortKey(m.decodeString(keys[j].key)) < m.sortKey(m.decodeString(keys[j-1].key)) {
+			log.Debugf("the keys of block %d of %v%v are not sorted as expected, it's searched linearly", i, m.header.Title, m.t)
+			d.sorted = false
+			break
+		}
+	}
+	m.keyCache.add(i, d)
+	return d, nil
+}
+
+// searchIndex returns the indexes of all the keys equal to word, in LookupIndex mode.
+func (m *MDict) searchIndex(word string) ([]int, error) {
+	return m.searchIndexFunc(word, func(k string) bool { return k == word })
+}
+
+// searchIndexFunc returns the indexes of all the keys which have the same sortKey as word and satisfy match.
+func (m *MDict) searchIndexFunc(word string, match func(string) bool) ([]int, error) {
+	key := m.sortKey(word)
+	// the first block whose last word is not less than the word,
+	// the word may span several blocks since different keys can have the same sortKey.
+	start := sort.Search(len(m.keyBlocks), func(i int) bool {
+		return m.sortKey(m.keyBlocks[i].lastWord) >= key
+	})
+	var res []int
+	for i := start; i < len(m.keyBlocks) && m.sortKey(m.keyBlocks[i].firstWord) <= key; i++ {
+		d, err := m.readKeyBlock(i)
+		if err != nil {
+			return res, err
+		}
+		keys, j := d.keys, 0
+		if d.sorted {
+			j = sort.Search(len(keys), func(j int) bool {
+				return m.sortKey(m.decodeString(keys[j].key)) >= key
+			})
+		}
+		for ; j < len(keys); j++ {
+			k := m.decodeString(keys[j].key)
+			if m.sortKey(k) != key {
+				if d.sorted {
+					break
+				}
+				continue
+			}
+			if match(k) {
+				res = append(res, m.keyBlocks[i].firstIndex+j)
+			}
+		}
+	}
+	return res, nil
+}
+
+// keyRange returns where the record of the index-th key starts and ends in the decompressed records,
+// end is math.MaxUint64 for the last key.
+func (m *MDict) keyRange(index int) (start uint64, end uint64, err error) {
+	if index < 0 || index >= m.numEntries {
+		return 0, 0, fmt.Errorf("%w: invalid index %d for %s%s", ErrCorrupt, index, m.header.Title, m.t)
+	}
+	end = math.MaxUint64
+	if m.Lookup != LookupIndex {
+		if index < len(m.keys)-1 {
+			end = m.keys[index+1].offset
+		}
+		return m.keys[index].offset, end, nil
+	}
+	i := sort.Search(len(m.keyBlocks), func(i int) bool {
+		return m.keyBlocks[i].firstIndex > index
+	}) - 1
+	d, err := m.readKeyBlock(i)
+	if err != nil {
+		return 0, 0, err
+	}
+	keys := d.keys
+	j := index - m.keyBlocks[i].firstIndex
+	if j+1 < len(keys) {
+		end = keys[j+1].offset
+	} else if i+1 < len(m.keyBlocks) {
+		next, err := m.readKeyBlock(i + 1)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = next.keys[0].offset
+	}
+	return keys[j].offset, end, nil
+}
+
+// allKeys returns all the keys in order, they are decoded from the key blocks in LookupIndex mode.
+func (m *MDict) allKeys() ([]keyOffset, error) {
+	if m.Lookup != LookupIndex {
+		return m.keys, nil
+	}
+	res := make([]keyOffset, 0, m.numEntries)
+	for i := range m.keyBlocks {
+		d, err := m.readKeyBlock(i)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d.keys...)
+	}
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LookupIndex(t *testing.T) {
+	var entries [][2]string
+	for i := 0; i < 200; i++ {
+		entries = append(entries, [2]string{fmt.Sprintf("word%03d", i), fmt.Sprintf("<p>definition of word %d</p>", i)})
+	}
+	entries = append(entries,
+		[2]string{"August", "the eighth month"},
+		[2]string{"august", "majestic"},
+		[2]string{"dup", "the first dup"},
+		[2]string{"dup", "the second dup"},
+		[2]string{"a-b", "from a to b"},
+		[2]string{"link", "@@@LINK=word042"},
+	)
+	norm := func(s string) string {
+		return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(s))
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return norm(entries[i][0]) < norm(entries[j][0]) })
+	b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 7, recordBlockSize: 100}
+	name := b.build(t)
+
+	keymap := MDict{}
+	assert.Nil(t, keymap.Decode(name, true))
+	index := MDict{Lookup: LookupIndex}
+	assert.Nil(t, index.Decode(name, true))
+	assert.Empty(t, index.keys)
+	assert.Equal(t, 30, len(index.keyBlocks))
+
+	for _, e := range entries {
+		assert.Equal(t, mustGetAll(t, &keymap, e[0]), mustGetAll(t, &index, e[0]), e[0])
+	}
+	assert.Equal(t, []string{"the first dup", "the second dup"}, mustGetAll(t, &index, "dup"))
+	assert.Equal(t, "majestic", mustGet(t, &index, "august"))
+	assert.Equal(t, "the eighth month", mustGet(t, &index, "August"))
+	assert.Equal(t, "<p>definition of word 42</p>", mustGet(t, &index, "link"))
+	assert.Equal(t, "", mustGet(t, &index, "AUGUST"))
+	assert.Equal(t, "", mustGet(t, &index, "word1000"))
+	assert.Equal(t, "", mustGet(t, &index, "aaa"))
+	assert.Equal(t, "", mustGet(t, &index, "zzz"))
+	assert.ElementsMatch(t, keymap.Keys(), index.Keys())
+
+	d1, err := keymap.DumpDict()
+	assert.Nil(t, err)
+	d2, err := index.DumpDict()
+	assert.Nil(t, err)
+	assert.Equal(t, d1, d2)
+}
+
+func Test_LookupIndexUnsorted(t *testing.T) {
+	// sorted by the bytes, rather than case-insensitively
+	entries := [][2]string{{"Zebra", "an animal"}, {"apple", "a fruit"}, {"banana", "another fruit"}}
+	b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 1}
+	name := b.build(t)
+
+	m := MDict{Lookup: LookupIndex}
+	assert.Nil(t, m.Decode(name, true))
+	assert.Equal(t, LookupKeymap, m.Lookup, "the index can't be binary-searched")
+	for _, e := range entries {
+		assert.Equal(t, e[1], mustGet(t, &m, e[0]))
+	}
+}
+
+func Test_LookupIndexUnsortedBlock(t *testing.T) {
+	// the first/last words of the block are sorted, but the keys inside are not
+	entries := [][2]string{{"apple", "a fruit"}, {"zoo", "a place"}, {"banana", "another fruit"}, {"zz", "sleep"}}
+	b := mdxBuilder{version: "2.0", entries: entries, keysPerBlock: 4}
+	name := b.build(t)
+
+	m := MDict{Lookup: LookupIndex}
+	assert.Nil(t, m.Decode(name, true))
+	assert.Equal(t, LookupIndex, m.Lookup)
+	for _, e := range entries {
+		assert.Equal(t, e[1], mustGet(t, &m, e[0]))
+	}
+}
@@ -0,0 +1,43 @@
+package decoder
+
+// Info is the metadata of an MDX/MDD file, mostly from its header.
+type Info struct {
+	File          string `json:"file"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	CreationDate  string `json:"creation_date"`
+	EngineVersion string `json:"engine_version"`
+	Encoding      string `json:"encoding"`
+	Format        string `json:"format"`
+	Encrypted     bool   `json:"encrypted"`
+	Entries       int    `json:"entries"`
+	KeyBlocks     int    `json:"key_blocks"`
+	RecordBlocks  int    `json:"record_blocks"`
+	// RecordsSize is the size of all the records after decompression.
+	RecordsSize uint64 `json:"records_size"`
+	FileSize    int64  `json:"file_size"`
+}
+
+// Info returns the metadata of a decoded MDict.
+func (m *MDict) Info() Info {
+	info := Info{
+		Title:         m.header.Title,
+		Description:   m.header.Description,
+		CreationDate:  m.header.CreationDate,
+		EngineVersion: m.header.GeneratedByEngineVersion,
+		Encoding:      m.encoding,
+		Format:        m.header.Format,
+		Encrypted:     m.encrypted != 0,
+		Entries:       m.numEntries,
+		KeyBlocks:     len(m.keyBlocks),
+		RecordBlocks:  len(m.recordBlockSizes),
+		FileSize:      m.fileSize,
+	}
+	if m.file != nil {
+		info.File = m.file.Name()
+	}
+	for _, b := range m.recordBlockSizes {
+		info.RecordsSize += b.DecompSize
+	}
+	return info
+}
@@ -0,0 +1,39 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Info(t *testing.T) {
+	e := Encoder{
+		Header:          Header{Title: "Team Glossary", Description: "terms we use", CreationDate: "2024-1-2"},
+		RecordBlockSize: 16,
+	}
+	var out bytes.Buffer
+	dict := map[string]string{"ack": "acknowledgement", "nack": "negative acknowledgement", "lgtm": "looks good to me"}
+	assert.Nil(t, e.WriteMDX(&out, dict))
+	name := filepath.Join(t.TempDir(), "glossary.mdx")
+	assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+
+	m := MDict{}
+	assert.Nil(t, m.Decode(name, true))
+	info := m.Info()
+	assert.Equal(t, name, info.File)
+	assert.Equal(t, "Team Glossary", info.Title)
+	assert.Equal(t, "terms we use", info.Description)
+	assert.Equal(t, "2024-1-2", info.CreationDate)
+	assert.Equal(t, "2.0", info.EngineVersion)
+	assert.Equal(t, "UTF-8", info.Encoding)
+	assert.Equal(t, "Html", info.Format)
+	assert.False(t, info.Encrypted)
+	assert.Equal(t, 3, info.Entries)
+	assert.Equal(t, 1, info.KeyBlocks)
+	assert.Equal(t, 3, info.RecordBlocks)
+	assert.Equal(t, uint64(len("acknowledgementnegative acknowledgementlooks good to me")), info.RecordsSize)
+	assert.Equal(t, int64(out.Len()), info.FileSize)
+}
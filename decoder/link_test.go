@@ -0,0 +1,45 @@
+package decoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetLinks(t *testing.T) {
+	b := mdxBuilder{version: "2.0", entries: [][2]string{
+		{"a", "@@@LINK=b\r\n\x00"},
+		{"b", "@@@LINK=c\r\n\x00"},
+		{"c", "the definition of c"},
+		{"cycle", "@@@LINK=loop"},
+		{"loop", "@@@LINK=cycle"},
+		{"dup", "the first dup"},
+		{"dup", "@@@LINK=c"},
+		{"dup", "the third dup"},
+		{"twice", "@@@LINK=c"},
+		{"twice", "@@@LINK=a"},
+		{"missing", "@@@LINK=nowhere"},
+		{"tormenter", "@@@LINK=tormentor"},
+		{"tormentor", "someone who torments"},
+	}}
+	m := MDict{}
+	assert.Nil(t, m.Decode(b.build(t), true))
+
+	assert.Equal(t, "the definition of c", mustGet(t, &m, "a"))
+	assert.Equal(t, "someone who torments", mustGet(t, &m, "tormenter"))
+	assert.Equal(t, "", mustGet(t, &m, "cycle"))
+	assert.Equal(t, "", mustGet(t, &m, "missing"))
+	assert.Equal(t, []string{"the first dup", "the definition of c", "the third dup"}, mustGetAll(t, &m, "dup"))
+	assert.Equal(t, "the first dup", mustGet(t, &m, "dup"))
+	assert.Equal(t, []string{"the definition of c", "the definition of c"}, mustGetAll(t, &m, "twice"))
+}
+
+func Test_LinkTarget(t *testing.T) {
+	target, ok := LinkTarget("@@@LINK=tormentor\r\n\x00")
+	assert.True(t, ok)
+	assert.Equal(t, "tormentor", target)
+	_, ok = LinkTarget("<b>tormentor</b>")
+	assert.False(t, ok)
+	_, ok = LinkTarget("@@@LINK=")
+	assert.False(t, ok)
+}
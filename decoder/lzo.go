@@ -0,0 +1,184 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A pure Go LZO1X decompressor, which is used by some (mostly older) MDX/MDD files for their key and record blocks.
+// It follows the reference implementation lzo1x_d.ch in LZO by Markus F.X.J. Oberhumer,
+// refer to https://www.oberhumer.com/opensource/lzo/ or lib/lzo/lzo1x_decompress_safe.c in the Linux kernel.
+
+var errLZOInputOverrun = errors.New("lzo: input overrun")
+
+type lzoDecoder struct {
+	in  []byte
+	ip  int
+	out []byte
+}
+
+func (d *lzoDecoder) next() (byte, error) {
+	if d.ip >= len(d.in) {
+		return 0, errLZOInputOverrun
+	}
+	b := d.in[d.ip]
+	d.ip++
+	return b, nil
+}
+
+// runLength decodes a run length encoded as a series of zero bytes followed by a non-zero byte,
+// each zero byte stands for 255, base is added to the result.
+func (d *lzoDecoder) runLength(base int) (int, error) {
+	n := 0
+	for {
+		b, err := d.next()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0 {
+			return n + base + int(b), nil
+		}
+		n += 255
+	}
+}
+
+func (d *lzoDecoder) le16() (int, error) {
+	if d.ip+2 > len(d.in) {
+		return 0, errLZOInputOverrun
+	}
+	v := int(d.in[d.ip]) | int(d.in[d.ip+1])<<8
+	d.ip += 2
+	return v, nil
+}
+
+func (d *lzoDecoder) copyLiterals(n int) error {
+	if d.ip+n > len(d.in) {
+		return errLZOInputOverrun
+	}
+	d.out = append(d.out, d.in[d.ip:d.ip+n]...)
+	d.ip += n
+	return nil
+}
+
+// copyMatch copies n bytes starting at "dist" bytes before the current output position.
+// The source and the destination may overlap, so it must be done byte by byte.
+func (d *lzoDecoder) copyMatch(dist int, n int) error {
+	pos := len(d.out) - dist
+	if dist <= 0 || pos < 0 {
+		return fmt.Errorf("lzo: lookbehind overrun, distance %d, output %d", dist, len(d.out))
+	}
+	for i := 0; i < n; i++ {
+		d.out = append(d.out, d.out[pos+i])
+	}
+	return nil
+}
+
+// lzo1xDecompress decompresses a raw LZO1X stream (without any headers).
+// sizeHint is the expected decompressed size, it can be 0 if unknown.
+func lzo1xDecompress(in []byte, sizeHint int) ([]byte, error) {
+	d := &lzoDecoder{in: in, out: make([]byte, 0, sizeHint)}
+	// state is the number of literals copied after the last instruction,
+	// 0 for none, 1~3 for short trailing literals, 4 for a literal run.
+	state := 0
+	if len(in) > 0 && in[0] > 17 {
+		d.ip++
+		t := int(in[0]) - 17
+		if err := d.copyLiterals(t); err != nil {
+			return nil, err
+		}
+		if t < 4 {
+			state = t
+		} else {
+			state = 4
+		}
+	}
+	for {
+		t, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		var dist, length, trailing int
+		switch {
+		case t < 16 && state == 0: // a literal run
+			n := int(t)
+			if n == 0 {
+				if n, err = d.runLength(15); err != nil {
+					return nil, err
+				}
+			}
+			if err := d.copyLiterals(n + 3); err != nil {
+				return nil, err
+			}
+			state = 4
+			continue
+		case t < 16 && state < 4: // M1, a 2-byte match right after a short literal copy
+			h, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			trailing = int(t & 3)
+			dist = 1 + int(t>>2) + int(h)<<2
+			length = 2
+		case t < 16: // M1, a 3-byte match after a literal run
+			h, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			trailing = int(t & 3)
+			dist = 1 + 0x0800 + int(t>>2) + int(h)<<2
+			length = 3
+		case t >= 64: // M2
+			h, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			trailing = int(t & 3)
+			dist = 1 + int((t>>2)&7) + int(h)<<3
+			length = int(t>>5) + 1
+		case t >= 32: // M3
+			length = int(t&31) + 2
+			if length == 2 {
+				if length, err = d.runLength(33); err != nil {
+					return nil, err
+				}
+			}
+			v, err := d.le16()
+			if err != nil {
+				return nil, err
+			}
+			trailing = v & 3
+			dist = 1 + v>>2
+		default: // M4, or the end of stream
+			high := int(t&8) << 11
+			length = int(t&7) + 2
+			if length == 2 {
+				if length, err = d.runLength(9); err != nil {
+					return nil, err
+				}
+			}
+			v, err := d.le16()
+			if err != nil {
+				return nil, err
+			}
+			trailing = v & 3
+			dist = high + v>>2
+			if dist == 0 {
+				if length != 3 {
+					return nil, fmt.Errorf("lzo: bad end of stream marker")
+				}
+				if d.ip != len(d.in) {
+					return nil, fmt.Errorf("lzo: %d bytes left after the end of stream", len(d.in)-d.ip)
+				}
+				return d.out, nil
+			}
+			dist += 0x4000
+		}
+		if err := d.copyMatch(dist, length); err != nil {
+			return nil, err
+		}
+		if err := d.copyLiterals(trailing); err != nil {
+			return nil, err
+		}
+		state = trailing
+	}
+}
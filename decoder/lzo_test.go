@@ -0,0 +1,68 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/adler32"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lzo1x.bin is the LZO1X compressed content of
+// doctor_mdx.html + doctor_ldoce.html[:4096] + doctor_mdx.html,
+// so that all kinds of instructions, including the far (M4) matches, are covered.
+func lzoFixture(t *testing.T) (compressed []byte, plain []byte) {
+	compressed, err := os.ReadFile("../testdata/lzo1x.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdx, err := os.ReadFile("../testdata/doctor_mdx.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ldoce, err := os.ReadFile("../testdata/doctor_ldoce.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain = append(append(append(plain, mdx...), ldoce[:4096]...), mdx...)
+	return compressed, plain
+}
+
+func Test_lzo1xDecompress(t *testing.T) {
+	compressed, plain := lzoFixture(t)
+	out, err := lzo1xDecompress(compressed, len(plain))
+	assert.Nil(t, err)
+	assert.Equal(t, plain, out)
+
+	// the size hint is only a hint
+	out, err = lzo1xDecompress(compressed, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, plain, out)
+}
+
+func Test_lzo1xDecompressCorrupt(t *testing.T) {
+	compressed, _ := lzoFixture(t)
+	_, err := lzo1xDecompress(compressed[:len(compressed)/2], 0)
+	assert.NotNil(t, err)
+	_, err = lzo1xDecompress(compressed[:len(compressed)-1], 0)
+	assert.NotNil(t, err)
+	_, err = lzo1xDecompress(nil, 0)
+	assert.NotNil(t, err)
+}
+
+func Test_decompressLZOBlock(t *testing.T) {
+	compressed, plain := lzoFixture(t)
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, adler32.Checksum(plain))
+	out, err := decompress([]byte{1, 0, 0, 0}, checksum, compressed)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(plain, out))
+
+	_, err = decompress([]byte{1, 0, 0, 0}, checksum, compressed[:len(compressed)-1])
+	assert.ErrorIs(t, err, ErrCorrupt)
+	checksum[0]++
+	_, err = decompress([]byte{1, 0, 0, 0}, checksum, compressed)
+	assert.ErrorIs(t, err, ErrChecksum)
+}
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"compress/zlib"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -21,12 +22,12 @@ import (
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode/utf16"
-	"unicode/utf8"
 
 	"github.com/schollz/progressbar/v3"
 	log "github.com/sirupsen/logrus"
@@ -40,22 +41,43 @@ type keyOffset struct {
 }
 
 type MDict struct {
+	// UserID and RegCode are needed for the registered dictionaries whose keyword headers are encrypted,
+	// UserID is the email or the device id, according to "RegisterBy" in the header, RegCode is hex-encoded.
+	UserID  string
+	RegCode string
+	// Lookup decides how the keys are kept in memory, see LookupMode.
+	Lookup LookupMode
+	// CacheBlocks is the max number of decompressed record blocks kept in memory,
+	// DefaultCacheBlocks is used if it's 0, and negative values disable the cache.
+	CacheBlocks int
+	// StyleSheet enables the substitution of the StyleSheet in the header for the `N` tags in the records.
+	StyleSheet bool
+
 	t          string
 	header     Header
+	styles     map[string]style
+	version    float64 // GeneratedByEngineVersion, e.g. 1.2 or 2.0
 	encrypted  int8
 	encoding   string
-	regCode    string
 	numEntries int
-	keys       []keyOffset
+	keys       []keyOffset // empty in LookupIndex mode
+	keyBlocks  []keyBlock
 	records    []byte
 	lazyOffset int
 
 	once   sync.Once
-	keymap map[string]uint64 // key: key value: the index of the key in MDict.keys
+	keymap map[string]uint64   // key: key value: the index of the key in MDict.keys
+	dups   map[string][]uint64 // the indexes of the keys appearing more than once, except the first one
+
+	resOnce   sync.Once
+	resources map[string]int // key: ResourceKey of the key, value: the index of the key, only for MDD files
 
 	file             *os.File // the raw fd, to avoid store all "records" bytes, they are memory-head
+	fileSize         int64
 	recordHeader     recordSection
 	recordBlockSizes []recordBlock
+	recordCache      *lru[[]byte]
+	keyCache         *lru[decodedKeyBlock] // the decoded key blocks in LookupIndex mode
 }
 
 type Header struct {
@@ -74,36 +96,115 @@ type Header struct {
 	StyleSheet               string `xml:"StyleSheet,attr"`
 	RegisterBy               string `xml:"RegisterBy,attr"`
 	RegCode                  string `xml:"RegCode,attr"`
+	StripKey                 string `xml:"StripKey,attr"`
 }
 
-func (m *MDict) Get(word string) string {
-	m.dumpKeys()
+// LinkPrefix marks a redirect record, e.g. "@@@LINK=tormentor" for the key "tormenter".
+const LinkPrefix = "@@@LINK="
+
+// MaxLinkDepth is the max number of redirects followed for one lookup.
+const MaxLinkDepth = 8
+
+// LinkTarget returns the target key if def is a redirect record.
+func LinkTarget(def string) (string, bool) {
+	if !strings.HasPrefix(def, LinkPrefix) {
+		return "", false
+	}
+	target := strings.TrimPrefix(def, LinkPrefix)
+	if i := strings.IndexAny(target, "\r\n\x00"); i >= 0 {
+		target = target[:i]
+	}
+	target = strings.TrimSpace(target)
+	return target, target != ""
+}
+
+// Get returns the first definition of word, redirects are followed.
+func (m *MDict) Get(word string) (string, error) {
+	defs, err := m.GetAll(word)
+	if len(defs) > 0 {
+		return defs[0], err
+	}
+	return "", err
+}
+
+// GetAll returns all the definitions of word, since a key may appear more than once in a dictionary.
+// Redirect records ("@@@LINK=") are followed, with cycle detection and a depth limit.
+func (m *MDict) GetAll(word string) ([]string, error) {
 	log.Debugf("Get %v from MDict", word)
-	if index, ok := m.keymap[word]; ok {
-		return m.decodeString(m.ReadAtOffset(int(index)))
+	return m.getAll(word, make(map[string]bool), 0)
+}
+
+func (m *MDict) getAll(word string, visited map[string]bool, depth int) ([]string, error) {
+	if visited[word] {
+		log.Debugf("link cycle detected at %q", word)
+		return nil, nil
+	}
+	if depth > MaxLinkDepth {
+		log.Debugf("too many links when resolving %q", word)
+		return nil, nil
+	}
+	// visited only tracks the current link chain, so that several records linking to the same target all resolve
+	visited[word] = true
+	defer delete(visited, word)
+	indexes, err := m.lookup(word)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, i := range indexes {
+		record, err := m.ReadAtOffset(i)
+		if err != nil {
+			return res, err
+		}
+		def := m.decodeString(record)
+		if target, ok := LinkTarget(def); ok {
+			log.Debugf("follow link: %q -> %q", word, target)
+			defs, err := m.getAll(target, visited, depth+1)
+			res = append(res, defs...)
+			if err != nil {
+				return res, err
+			}
+			continue
+		}
+		res = append(res, def)
+	}
+	return res, nil
+}
+
+// lookup returns the indexes of all the keys equal to word.
+func (m *MDict) lookup(word string) ([]int, error) {
+	if m.Lookup == LookupIndex {
+		return m.searchIndex(word)
+	}
+	m.dumpKeys()
+	index, ok := m.keymap[word]
+	if !ok {
+		return nil, nil
+	}
+	res := []int{int(index)}
+	for _, i := range m.dups[word] {
+		res = append(res, int(i))
 	}
-	return ""
+	return res, nil
 }
 
 func (m *MDict) dumpKeys() {
 	m.once.Do(func() {
 		m.keymap = make(map[string]uint64, m.numEntries)
+		m.dups = make(map[string][]uint64)
 		bar := progressbar.Default(int64(m.numEntries), fmt.Sprintf("dumping keys for %v(%v)", m.header.Title, m.t))
 		for i, k := range m.keys {
 			key := m.decodeString(k.key)
 			_ = bar.Add(1)
-			if e, ok := m.keymap[key]; ok {
-				// TODO: something like xxxx -> @@@LINK=lack
-				log.Debugf("key existed: %v, %v->[%v], now: %v->%v",
-					key, m.keys[e].key, m.decodeString(m.ReadAtOffset(int(e))),
-					k, m.decodeString(m.ReadAtOffset(i)),
-				)
+			if _, ok := m.keymap[key]; ok {
+				log.Debugf("key existed: %v, keep all of them", key)
+				m.dups[key] = append(m.dups[key], uint64(i))
 				continue
 			}
 			m.keymap[key] = uint64(i)
 		}
 		if len(m.keymap) != m.numEntries {
-			log.Warnf("dumpKeys: num entries number does not match: map[%d], numEntries:%v", len(m.keymap), m.numEntries)
+			log.Debugf("dumpKeys: %d distinct keys, numEntries: %v", len(m.keymap), m.numEntries)
 		}
 		runtime.GC()
 		debug.FreeOSMemory()
@@ -112,6 +213,22 @@ func (m *MDict) dumpKeys() {
 }
 
 func (m *MDict) Keys() []string {
+	if m.Lookup == LookupIndex {
+		keys, err := m.allKeys()
+		if err != nil {
+			log.Warnf("read keys of %v%v err: %v", m.header.Title, m.t, err)
+		}
+		seen := make(map[string]bool, len(keys))
+		res := make([]string, 0, len(keys))
+		for _, k := range keys {
+			key := m.decodeString(k.key)
+			if !seen[key] {
+				seen[key] = true
+				res = append(res, key)
+			}
+		}
+		return res
+	}
 	m.dumpKeys()
 	res := make([]string, 0, len(m.keymap))
 	for k := range m.keymap {
@@ -124,7 +241,7 @@ func (m *MDict) Close(fileName string, fzf bool) error {
 	return m.file.Close()
 }
 
-func (m *MDict) Decode(fileName string, fzf bool) error {
+func (m *MDict) Decode(fileName string, fzf bool) (err error) {
 	start := time.Now()
 	defer func() {
 		log.Debugf("decode cost: %v", time.Since(start))
@@ -140,7 +257,17 @@ func (m *MDict) Decode(fileName string, fzf bool) error {
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
 	m.file = file
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	m.fileSize = stat.Size()
 
 	var headerLen uint32
 	if err := binary.Read(file, binary.BigEndian, &headerLen); err != nil {
@@ -151,7 +278,10 @@ func (m *MDict) Decode(fileName string, fzf bool) error {
 
 	// It must be even, cuz head_str is UTF-16 encoded
 	if headerLen%2 != 0 {
-		log.Fatalf("headerLen must be even, but got %v", headerLen)
+		return fmt.Errorf("%w: headerLen must be even, but got %v", ErrCorrupt, headerLen)
+	}
+	if int64(headerLen) > m.fileSize {
+		return fmt.Errorf("%w: headerLen %v exceeds the file size %v", ErrCorrupt, headerLen, m.fileSize)
 	}
 	var headerBytes = make([]uint8, headerLen)
 
@@ -166,7 +296,7 @@ func (m *MDict) Decode(fileName string, fzf bool) error {
 	}
 	m.lazyOffset += 4
 	if adler32.Checksum(headerBytes) != checksum {
-		return fmt.Errorf("the checksum of header str does not match")
+		return fmt.Errorf("%w: the checksum of header str does not match", ErrChecksum)
 	}
 
 	headerSize := headerLen / 2
@@ -180,34 +310,47 @@ func (m *MDict) Decode(fileName string, fzf bool) error {
 
 	var header Header
 	if err := xml.Unmarshal([]byte(headerXML), &header); err != nil {
-		return err
+		return fmt.Errorf("%w: bad header %q: %v", ErrCorrupt, headerXML, err)
 	}
 
 	log.Debugf("header as structured: %+v\n", header)
-	if header.GeneratedByEngineVersion != "2.0" {
-		log.Fatalf("TODO: Only 2.0 Version is supported!, but the input MDX file was generated by engine: %v",
-			header.GeneratedByEngineVersion)
+	version, err := strconv.ParseFloat(strings.TrimSpace(header.GeneratedByEngineVersion), 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad engine version %q: %v", ErrUnsupportedVersion, header.GeneratedByEngineVersion, err)
+	}
+	if version >= 3.0 {
+		return fmt.Errorf("%w: only engine version 1.x and 2.0 are supported, but the input file was generated by engine: %v",
+			ErrUnsupportedVersion, header.GeneratedByEngineVersion)
 	}
+	m.version = version
 	m.header = header
+	m.styles = parseStyleSheet(header.StyleSheet)
 	m.encoding = header.Encoding
 	if m.t == ".mdd" {
 		m.encoding = "UTF-16"
 	}
 
-	encrypt, err := strconv.Atoi(header.Encrypted)
-	if err != nil {
-		return err
+	switch header.Encrypted {
+	case "", "No":
+		m.encrypted = 0
+	case "Yes":
+		m.encrypted = 1
+	default:
+		encrypt, err := strconv.Atoi(header.Encrypted)
+		if err != nil {
+			return err
+		}
+		m.encrypted = int8(encrypt)
 	}
-	m.encrypted = int8(encrypt)
 
 	if err := m.decodeKeyWordSection(file); err != nil {
-		return fmt.Errorf("decode keyword section: %v", err)
+		return fmt.Errorf("decode keyword section: %w", err)
 	}
 	// offset, err := file.Seek(0, io.SeekCurrent)
 	// log.Debugf("offset of Record start: %v, err: %v", offset, err)
 	x := time.Now()
 	if err := m.decodeRecordSection(file, fzf); err != nil {
-		return fmt.Errorf("decode record section: %v", err)
+		return fmt.Errorf("decode record section: %w", err)
 	}
 	log.Debugf("decode record cost: %v", time.Since(x))
 	// The reader should be at EOF now
@@ -224,6 +367,11 @@ func (m *MDict) Decode(fileName string, fzf bool) error {
 	return nil
 }
 
+// DecodeString decodes a key or a record with the encoding of the dictionary, e.g. Entry.Record.
+func (m *MDict) DecodeString(b []byte) string {
+	return m.decodeString(b)
+}
+
 func (m *MDict) decodeString(b []byte) string {
 	if m.encoding == "UTF-16" {
 		runes := make([]uint16, len(b)/2)
@@ -235,76 +383,97 @@ func (m *MDict) decodeString(b []byte) string {
 	return string(b)
 }
 
-func (m *MDict) fetchNthRecordBlock(i int, bytesBefore int) []byte {
-	log.Tracef("fetchNthRecordBlock: %d, bytesBefore: %d, CompSize: %v", i, bytesBefore, m.recordBlockSizes[i].CompSize)
-	compressed1 := make([]byte, m.recordBlockSizes[i].CompSize)
-	n, err := m.file.ReadAt(compressed1, int64(m.lazyOffset+bytesBefore))
+func (m *MDict) fetchNthRecordBlock(i int) ([]byte, error) {
+	if decompressed, ok := m.recordCache.get(i); ok {
+		return decompressed, nil
+	}
+	decompressed, err := m.readRecordBlock(i)
 	if err != nil {
-		log.Fatalf("read at err %v", err)
+		return nil, err
 	}
-	if n != int(m.recordBlockSizes[i].CompSize) {
-		log.Fatalf("read %v bytes, but expected %v bytes", n, m.recordBlockSizes[i].CompSize)
+	m.recordCache.add(i, decompressed)
+	return decompressed, nil
+}
+
+// readRecordBlock reads and decompresses the ith record block, bypassing the cache.
+func (m *MDict) readRecordBlock(i int) ([]byte, error) {
+	block := m.recordBlockSizes[i]
+	log.Tracef("fetchNthRecordBlock: %d, compOffset: %d, CompSize: %v", i, block.compOffset, block.CompSize)
+	compressed1 := make([]byte, block.CompSize)
+	n, err := m.file.ReadAt(compressed1, int64(m.lazyOffset)+int64(block.compOffset))
+	if err != nil {
+		return nil, fmt.Errorf("%w: read record block %d: %v", ErrCorrupt, i, err)
 	}
 	if n == 0 {
-		return nil
+		return nil, nil
 	}
-	decompressed := decompress(compressed1[:4], compressed1[4:8], compressed1[8:])
-	if len(decompressed) != int(m.recordBlockSizes[i].DecompSize) {
-		log.Fatalf("decompressed length does not equal to expected")
+	decompressed, err := decompressBlock(compressed1, block.DecompSize)
+	if err != nil {
+		return nil, fmt.Errorf("record block %d: %w", i, err)
 	}
-	return decompressed
+	return decompressed, nil
 }
 
-func (m *MDict) ReadAtOffset(index int) []byte {
+// ReadAtOffset reads the record of the index-th key.
+func (m *MDict) ReadAtOffset(index int) ([]byte, error) {
 	log.Tracef("m.keys len: %v, try to access: %v, nblock: %d[%d]", len(m.keys), index, len(m.recordBlockSizes), m.recordHeader.NumBlocks)
-	if index >= len(m.keys) {
-		log.Fatalf("invalid index for %s.%s", m.header.Title, m.t)
-	}
-
-	var offset1 uint64 = math.MaxUint64
-	if index < len(m.keys)-1 {
-		offset1 = m.keys[index+1].offset
-	}
-	offset := m.keys[index].offset
-	total := uint64(0)
-	totalDecomp := uint64(0)
-	pre := -1
-	preDecomp := -1
-	iBlock := -1
-	i1Block := -1
-	for i := uint64(0); i < m.recordHeader.NumBlocks; i++ {
-		log.Tracef("%d block: %v->%v", i, m.recordBlockSizes[i].CompSize, m.recordBlockSizes[i].DecompSize)
-		pre = int(total)
-		preDecomp = int(totalDecomp)
-		total += m.recordBlockSizes[i].CompSize
-		totalDecomp += m.recordBlockSizes[i].DecompSize
-		if offset < totalDecomp {
-			iBlock = int(i)
-			if offset1 < totalDecomp {
-				i1Block = iBlock
-			} else {
-				i1Block = iBlock + 1
-			}
-			break
-		}
+	offset, offset1, err := m.keyRange(index)
+	if err != nil {
+		return nil, err
+	}
+	record, err := m.readRecord(offset, offset1)
+	if err != nil {
+		return nil, err
+	}
+	return m.styled(record), nil
+}
+
+// recordEnd returns where the record of keys[i] ends, see readRecord.
+func recordEnd(keys []keyOffset, i int) uint64 {
+	if i < len(keys)-1 {
+		return keys[i+1].offset
+	}
+	return math.MaxUint64
+}
+
+// readRecord reads the decompressed records in [offset, offset1),
+// offset1 is math.MaxUint64 for the last record.
+// The returned bytes may be shared with the block cache, they should not be modified.
+func (m *MDict) readRecord(offset uint64, offset1 uint64) ([]byte, error) {
+	if offset1 < offset {
+		return nil, fmt.Errorf("%w: bad record range [%d, %d)", ErrCorrupt, offset, offset1)
+	}
+	blocks := m.recordBlockSizes
+	// the block containing offset
+	iBlock := sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].decompOffset+blocks[i].DecompSize > offset
+	})
+	log.Tracef("offset: %v, offset1: %v, iBlock: %d", offset, offset1, iBlock)
+	if iBlock == len(blocks) {
+		return nil, fmt.Errorf("%w: doesn't find a valid block for offset %d", ErrCorrupt, offset)
+	}
+	start := offset - blocks[iBlock].decompOffset
+	decompressed, err := m.fetchNthRecordBlock(iBlock)
+	if err != nil {
+		return nil, err
 	}
-	log.Tracef("index: %v, iBlock: %d, i1Block: %d, pre: %d, preDecomp: %d, total: %d, totalDecomp: %d, offset:%d",
-		index, iBlock, i1Block, pre, preDecomp, total, totalDecomp, m.lazyOffset)
-	if iBlock < 0 {
-		log.Fatalf("doesn't find a valid block for offset %d, index: %d", iBlock, index)
+	if offset1 <= blocks[iBlock].decompOffset+blocks[iBlock].DecompSize { // | ---*--*- | -------- |
+		end := offset1 - blocks[iBlock].decompOffset
+		return decompressed[start:end:end], nil
+	}
+	// the record spans more than one block | ---*--- | -*-- |
+	res := append([]byte{}, decompressed[start:]...)
+	for i := iBlock + 1; i < len(blocks) && blocks[i].decompOffset < offset1; i++ {
+		decompressed, err := m.fetchNthRecordBlock(i)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, decompressed...)
 	}
-	decompressed := m.fetchNthRecordBlock(iBlock, pre)
-	offset = offset - uint64(preDecomp)
-	offset1 = offset1 - uint64(preDecomp)
-	if iBlock == i1Block { // | ---*--*- | -------- |
-		return decompressed[offset:offset1]
-	} else if i1Block < len(m.recordBlockSizes) { // | ---*--- | -*-- |
-		decompressed1 := m.fetchNthRecordBlock(i1Block, int(total))
-		decompressed = append(decompressed, decompressed1...)
-		return decompressed[offset:offset1]
+	if offset1 != math.MaxUint64 && offset1-offset < uint64(len(res)) {
+		res = res[:offset1-offset]
 	}
-	// i1Block == len(m.recordBlockSizes) , index is inside the last block | ---*--- |
-	return decompressed[offset:]
+	return res, nil
 }
 
 // DumpDict may cost quite a long time, use it when you actually need the whole data
@@ -316,11 +485,27 @@ func (m *MDict) DumpDict() (map[string]string, error) {
 	defer func() {
 		log.Debugf("dump dict cost: %v", time.Since(start))
 	}()
+	// the entries are exported concurrently, they are put in the order of the keys,
+	// so the last one wins if a key is duplicated, no matter which record block it's in
+	type kv struct{ key, def string }
+	entries := make([]*kv, m.numEntries)
+	err := m.Export(ExportOptions{}, func(e Entry) error {
+		if e.Index >= len(entries) {
+			return fmt.Errorf("%w: key %d is out of the %d entries", ErrCorrupt, e.Index, len(entries))
+		}
+		entries[e.Index] = &kv{e.Key, m.decodeString(e.Record)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	res := make(map[string]string, m.numEntries)
 	total := 0
-	for i, k := range m.keys {
-		res[m.decodeString(k.key)] = m.decodeString((m.ReadAtOffset(i)))
-		total += 1
+	for _, e := range entries {
+		if e != nil {
+			res[e.key] = e.def
+			total += 1
+		}
 	}
 	if total != m.numEntries {
 		return nil, fmt.Errorf("the keys not suffice, got: %v, expected: %v", total, m.numEntries)
@@ -334,11 +519,49 @@ func decryptRegCode(regCode []byte, id []byte) []byte {
 	return s20
 }
 
-func (m *MDict) salsaDecrypt(data []byte, id []byte, regCode []byte) ([]byte, error) {
-	key := decryptRegCode(regCode, id) // [32]byte
+// salsaDecrypt decrypts data with the key derived from RegCode and UserID.
+func (m *MDict) salsaDecrypt(data []byte) ([]byte, error) {
+	if m.UserID == "" || m.RegCode == "" {
+		return nil, fmt.Errorf("%w: the dictionary is registered by %q, UserID and RegCode are required", ErrEncrypted, m.header.RegisterBy)
+	}
+	regCode, err := hex.DecodeString(m.RegCode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad RegCode: %v", ErrEncrypted, err)
+	}
+	id := []byte(m.UserID)
+	if strings.EqualFold(m.header.RegisterBy, "EMail") {
+		// the email is encoded in UTF-16LE before digested
+		units := utf16.Encode([]rune(m.UserID))
+		id = make([]byte, 2*len(units))
+		for i, u := range units {
+			binary.LittleEndian.PutUint16(id[2*i:], u)
+		}
+	}
+	key := decryptRegCode(regCode, id)
 	return salsa20(data, key, [8]byte{}, 8), nil
 }
 
+// numberWidth is the width of the "number" fields in keyword and record sections,
+// 8 bytes for version >= 2.0, 4 bytes for the older ones.
+func (m *MDict) numberWidth() int {
+	if m.version >= 2.0 {
+		return 8
+	}
+	return 4
+}
+
+// readNumber reads a big-endian "number", see numberWidth.
+func (m *MDict) readNumber(r io.Reader) (uint64, error) {
+	if m.numberWidth() == 8 {
+		var n uint64
+		err := binary.Read(r, binary.BigEndian, &n)
+		return n, err
+	}
+	var n uint32
+	err := binary.Read(r, binary.BigEndian, &n)
+	return uint64(n), err
+}
+
 func (m *MDict) decodeKeyWordSection(fd io.Reader) error {
 	// num_blocks	8 bytes	Number of items in key_blocks. Big-endian. Possibly encrypted, see below.
 	// num_entries	8 bytes	Total number of keywords. Big-endian. Possibly encrypted, see below.
@@ -350,15 +573,22 @@ func (m *MDict) decodeKeyWordSection(fd io.Reader) error {
 	// key_blocks[0]	varying	A compressed block containing keywords, compressed. See below.
 	// ...	...	...
 	// key_blocks[num_blocks-1]	varying	...
+	//
+	// For version 1.x, all the numbers above are 4 bytes, key_index_decomp_len and checksum do not exist,
+	// and key_index is neither compressed nor encrypted.
 
 	log.Debugf("decoding keyword section")
-	var rawHeader = make([]byte, 40)
+	v2 := m.version >= 2.0
+	headerLen := 4 * m.numberWidth()
+	if v2 {
+		headerLen = 5 * m.numberWidth()
+	}
+	var rawHeader = make([]byte, headerLen)
 	if err := binary.Read(fd, binary.BigEndian, rawHeader); err != nil {
 		return err
 	}
-	m.lazyOffset += 40
+	m.lazyOffset += headerLen
 	var err error
-	h := bytes.NewReader(rawHeader)
 	type keywordSectionHeader struct {
 		NumBlock          uint64
 		NumEntries        uint64
@@ -367,26 +597,38 @@ func (m *MDict) decodeKeyWordSection(fd io.Reader) error {
 		KeyBlockLen       uint64
 	}
 	if m.encrypted&1 != 0 {
-		log.Fatal("TODO: keyword header encrypted, salsa20 not supported yet")
-		rawHeader, err = m.salsaDecrypt(rawHeader, []byte("TODO: userid"), []byte(m.regCode))
+		log.Debugf("keyword header encrypted, decrypt it")
+		rawHeader, err = m.salsaDecrypt(rawHeader)
 		if err != nil {
 			return err
 		}
 	}
 
 	var header keywordSectionHeader
-	if err := binary.Read(h, binary.BigEndian, &header); err != nil {
-		return err
+	h := bytes.NewReader(rawHeader)
+	fields := []*uint64{&header.NumBlock, &header.NumEntries, &header.KeyIndexDecompLen, &header.KeyIndexCompLen, &header.KeyBlockLen}
+	if !v2 {
+		fields = []*uint64{&header.NumBlock, &header.NumEntries, &header.KeyIndexCompLen, &header.KeyBlockLen}
 	}
-	var keywordHeaderChecksum [4]byte
-
-	if err := binary.Read(fd, binary.BigEndian, &keywordHeaderChecksum); err != nil {
-		return err
+	for _, f := range fields {
+		if *f, err = m.readNumber(h); err != nil {
+			return err
+		}
 	}
-	m.lazyOffset += 4
 
-	if adler32.Checksum(rawHeader) != binary.BigEndian.Uint32(keywordHeaderChecksum[:]) {
-		return fmt.Errorf("the checksum of keyword header does not match")
+	if v2 {
+		var keywordHeaderChecksum [4]byte
+		if err := binary.Read(fd, binary.BigEndian, &keywordHeaderChecksum); err != nil {
+			return err
+		}
+		m.lazyOffset += 4
+
+		if adler32.Checksum(rawHeader) != binary.BigEndian.Uint32(keywordHeaderChecksum[:]) {
+			if m.encrypted&1 != 0 {
+				return fmt.Errorf("%w: the checksum of keyword header does not match, check the UserID and RegCode", ErrEncrypted)
+			}
+			return fmt.Errorf("%w: the checksum of keyword header does not match", ErrChecksum)
+		}
 	}
 
 	m.numEntries = int(header.NumEntries)
@@ -401,88 +643,131 @@ func (m *MDict) decodeKeyWordSection(fd io.Reader) error {
 	// 		previous = buf[i];
 	// 	}
 	// }
+	// every entry takes some bytes in the file, which also bounds the allocations for the entries, e.g. in DumpDict
+	if header.KeyIndexCompLen > uint64(m.fileSize) || header.KeyBlockLen > uint64(m.fileSize) || header.NumEntries > uint64(m.fileSize) {
+		return fmt.Errorf("%w: bad keyword section header %+v", ErrCorrupt, header)
+	}
 	keyIndexEncrypted := make([]byte, header.KeyIndexCompLen)
 	if err := binary.Read(fd, binary.BigEndian, keyIndexEncrypted); err != nil {
 		return err
 	}
 	m.lazyOffset += int(header.KeyIndexCompLen)
-	compType := keyIndexEncrypted[:4]
-	compressedChecksum := keyIndexEncrypted[4:8]
-	// log.Debugf("len(keyIndexEncrypted): %v, %v:%v:%v", len(keyIndexEncrypted), keyIndexEncrypted[:4], keyIndexEncrypted[4:8], keyIndexEncrypted[8:])
-	keyIndexDecrypted := keyIndexEncrypted
-	encrypted := (m.encrypted & 2) != 0
-	if encrypted {
-		// Decrypt keyword Index if encrypted
-		// After this, we will get compressed keyword Index
-		log.Debugf("keyword index encrypted, decrypt it")
-		keyIndexDecrypted = keywordIndexDecrypt(keyIndexEncrypted)
-	}
-	// log.Debugf("len(keyIndexDecrypted): %v, %v:%v:%v", len(keyIndexDecrypted), keyIndexDecrypted[:4], keyIndexDecrypted[4:8], keyIndexDecrypted[8:])
-	keyIndexDecompressed := decompress(compType, compressedChecksum, keyIndexDecrypted[8:])
-
-	// log.Debugf("keyIndexDecompressed len: %d", len(keyIndexDecompressed))
-	if len(keyIndexDecompressed) != int(header.KeyIndexDecompLen) {
-		// TODO: according to the exsited Python implementation, this fields only existed in version >= 2
-		log.Fatalf("the length of decompressed part is wrong: expected: %v, got :%v", header.KeyIndexDecompLen, len(keyIndexDecompressed))
+	keyIndexDecompressed := keyIndexEncrypted
+	if v2 {
+		if len(keyIndexEncrypted) < 8 {
+			return fmt.Errorf("%w: the keyword index is too short", ErrCorrupt)
+		}
+		compType := keyIndexEncrypted[:4]
+		compressedChecksum := keyIndexEncrypted[4:8]
+		// log.Debugf("len(keyIndexEncrypted): %v, %v:%v:%v", len(keyIndexEncrypted), keyIndexEncrypted[:4], keyIndexEncrypted[4:8], keyIndexEncrypted[8:])
+		keyIndexDecrypted := keyIndexEncrypted
+		encrypted := (m.encrypted & 2) != 0
+		if encrypted {
+			// Decrypt keyword Index if encrypted
+			// After this, we will get compressed keyword Index
+			log.Debugf("keyword index encrypted, decrypt it")
+			keyIndexDecrypted = keywordIndexDecrypt(keyIndexEncrypted)
+		}
+		// log.Debugf("len(keyIndexDecrypted): %v, %v:%v:%v", len(keyIndexDecrypted), keyIndexDecrypted[:4], keyIndexDecrypted[4:8], keyIndexDecrypted[8:])
+		keyIndexDecompressed, err = decompress(compType, compressedChecksum, keyIndexDecrypted[8:])
+		if err != nil {
+			return fmt.Errorf("keyword index: %w", err)
+		}
+
+		// log.Debugf("keyIndexDecompressed len: %d", len(keyIndexDecompressed))
+		if len(keyIndexDecompressed) != int(header.KeyIndexDecompLen) {
+			return fmt.Errorf("%w: the length of decompressed keyword index is wrong: expected: %v, got :%v", ErrCorrupt, header.KeyIndexDecompLen, len(keyIndexDecompressed))
+		}
 	}
 	// Decode the decompressed keyword index part
 	r := bytes.NewReader(keyIndexDecompressed)
-	type keyBlock struct {
-		comp       uint64
-		decomp     uint64
-		numEntries uint64
-		firstWord  []byte
-		lastWord   []byte
+
+	// readWord reads the first/last word of a key block, whose size is
+	// the number of "basic units" for the encoding of the word.
+	// For version >= 2, the size is 2 bytes and the word is followed by a terminator,
+	// for version 1.x, the size is 1 byte and there is no terminator.
+	readWord := func() ([]byte, error) {
+		var size int
+		if v2 {
+			var s uint16
+			if err := binary.Read(r, binary.BigEndian, &s); err != nil {
+				return nil, err
+			}
+			size = int(s) + 1
+		} else {
+			var s uint8
+			if err := binary.Read(r, binary.BigEndian, &s); err != nil {
+				return nil, err
+			}
+			size = int(s)
+		}
+		if m.encoding == "UTF-16" {
+			size = size * 2
+		}
+		word := make([]byte, size)
+		if err := binary.Read(r, binary.BigEndian, word); err != nil {
+			return nil, err
+		}
+		return word, nil
 	}
 
 	var keyBlocks []keyBlock
 	totalEntries := 0
+	keyBlocksLen := 0
 	for i := 0; i < int(header.NumBlock); i++ {
-		var numEntries uint64
-		if err := binary.Read(r, binary.BigEndian, &numEntries); err != nil {
+		numEntries, err := m.readNumber(r)
+		if err != nil {
 			return err
 		}
 		totalEntries += int(numEntries)
-		var firstSize uint16 // the number of "basic units" for the encoding of the first word
-		if err := binary.Read(r, binary.BigEndian, &firstSize); err != nil {
-			return err
-		}
-		firstSize += 1 // why the "+1"? text_term --> termination? For version >=2
-		if m.encoding == "UTF-16" {
-			firstSize = firstSize * 2
-		}
-		firstWord := make([]byte, firstSize) // including the terminator
-		if err := binary.Read(r, binary.BigEndian, firstWord); err != nil {
-			return err
-		}
-		var lastSize uint16 // the number of "basic units" for the encoding of the first word
-		if err := binary.Read(r, binary.BigEndian, &lastSize); err != nil {
+		firstWord, err := readWord()
+		if err != nil {
 			return err
 		}
-		lastSize += 1
-		if m.encoding == "UTF-16" {
-			lastSize = lastSize * 2
-		}
-		lastWord := make([]byte, lastSize)
-		if err := binary.Read(r, binary.BigEndian, lastWord); err != nil {
+		lastWord, err := readWord()
+		if err != nil {
 			return err
 		}
 
-		var compSize uint64
-		if err := binary.Read(r, binary.BigEndian, &compSize); err != nil {
+		compSize, err := m.readNumber(r)
+		if err != nil {
 			return err
 		}
 		// log.Debugf("comp len of key_blocks[%d], %v\n", i, compSize)
 
-		var decompSize uint64
-		if err := binary.Read(r, binary.BigEndian, &decompSize); err != nil {
+		decompSize, err := m.readNumber(r)
+		if err != nil {
 			return err
 		}
 		// log.Debugf("decomp len of key_blocks[%d], %v\n", i, decompSize)
-		keyBlocks = append(keyBlocks, keyBlock{compSize, decompSize, numEntries, firstWord, lastWord})
-	}
+		keyBlocks = append(keyBlocks, keyBlock{
+			comp:       compSize,
+			decomp:     decompSize,
+			numEntries: numEntries,
+			firstWord:  strings.TrimRight(m.decodeString(firstWord), "\x00"),
+			lastWord:   strings.TrimRight(m.decodeString(lastWord), "\x00"),
+			fileOffset: int64(m.lazyOffset + keyBlocksLen),
+			firstIndex: totalEntries - int(numEntries),
+		})
+		keyBlocksLen += int(compSize)
+	}
+	m.keyBlocks = keyBlocks
 
 	log.Debugf("total entries: %v", totalEntries)
+	if m.Lookup == LookupIndex && !m.indexSorted() {
+		// e.g. the keys are sorted by another collation, or in the bytes of GBK/Big5, they can't be binary-searched
+		log.Warnf("the keys of %v%v are not sorted as expected, all the keys are loaded instead of the index", m.header.Title, m.t)
+		m.Lookup = LookupKeymap
+	}
+	if m.Lookup == LookupIndex {
+		// the key blocks will be read on demand
+		m.keyCache = newLRU[decodedKeyBlock](keyCacheBlocks)
+		if _, err := io.CopyN(io.Discard, fd, int64(keyBlocksLen)); err != nil {
+			return err
+		}
+		m.lazyOffset += keyBlocksLen
+		return nil
+	}
 	// decode key blocks
 	for _, b := range keyBlocks {
 		// log.Debugf("decoding [%d]th key block", i)
@@ -491,17 +776,21 @@ func (m *MDict) decodeKeyWordSection(fd io.Reader) error {
 			return err
 		}
 		m.lazyOffset += int(b.comp)
-		decompressed := decompress(compressed[:4], compressed[4:8], compressed[8:])
-		if len(decompressed) != int(b.decomp) {
-			log.Fatalf("decomp len not as expected!")
+		decompressed, err := decompressBlock(compressed, b.decomp)
+		if err != nil {
+			return fmt.Errorf("key block: %w", err)
 		}
-		m.splitKeyBlock(decompressed, int(b.numEntries))
+		keys, err := m.splitKeyBlock(decompressed, int(b.numEntries))
+		if err != nil {
+			return err
+		}
+		m.keys = append(m.keys, keys...)
 	}
 
 	return nil
 }
 
-func (m *MDict) splitKeyBlock(b []byte, keyNum int) {
+func (m *MDict) splitKeyBlock(b []byte, keyNum int) ([]keyOffset, error) {
 	// log.Debugf("block %d, num: %d, %v", index, keyNum, b)
 	delimiterWidth := 1
 	delimiter := []byte{0x00}
@@ -509,19 +798,33 @@ func (m *MDict) splitKeyBlock(b []byte, keyNum int) {
 		delimiterWidth = 2
 		delimiter = []byte{0x00, 0x00}
 	}
+	width := m.numberWidth()
+	if keyNum > len(b)/width {
+		return nil, fmt.Errorf("%w: %d keys in a key block of %d bytes", ErrCorrupt, keyNum, len(b))
+	}
+	res := make([]keyOffset, 0, keyNum)
 	p := 0
 	for i := 0; i < keyNum; i++ {
-		p += 8
-		offset := binary.BigEndian.Uint64(b[p-8 : p])
+		p += width
+		if p > len(b) {
+			return nil, fmt.Errorf("%w: key block ends unexpectedly, %d/%d keys", ErrCorrupt, i, keyNum)
+		}
+		var offset uint64
+		if width == 8 {
+			offset = binary.BigEndian.Uint64(b[p-width : p])
+		} else {
+			offset = uint64(binary.BigEndian.Uint32(b[p-width : p]))
+		}
 		keyBytes := make([]byte, 0)
-		for p < len(b) && (!reflect.DeepEqual(b[p:p+delimiterWidth], delimiter)) { // TODO: performance
+		for p+delimiterWidth <= len(b) && (!reflect.DeepEqual(b[p:p+delimiterWidth], delimiter)) { // TODO: performance
 			keyBytes = append(keyBytes, b[p:p+delimiterWidth]...)
 			p += delimiterWidth
 		}
 		p += delimiterWidth
 		// log.Debugf("splitKeyBlock key[%v][%v] at offset [%d]\n", len(m.keys), m.decodeString(keyBytes), offset)
-		m.keys = append(m.keys, keyOffset{offset, keyBytes})
+		res = append(res, keyOffset{offset, keyBytes})
 	}
+	return res, nil
 }
 
 type recordSection struct {
@@ -534,22 +837,30 @@ type recordSection struct {
 type recordBlock struct {
 	CompSize   uint64
 	DecompSize uint64
+
+	// where the block starts, in the compressed record blocks and the decompressed records respectively
+	compOffset   uint64
+	decompOffset uint64
 }
 
 func (m *MDict) decodeRecordSection(fd io.Reader, lazy bool) error {
 	var recordHeader recordSection
-	if err := binary.Read(fd, binary.BigEndian, &recordHeader); err != nil {
-		return err
+	for _, f := range []*uint64{&recordHeader.NumBlocks, &recordHeader.NumEntries, &recordHeader.IndexLen, &recordHeader.BlocksLen} {
+		n, err := m.readNumber(fd)
+		if err != nil {
+			return err
+		}
+		*f = n
 	}
-	m.lazyOffset += 8 * 4
+	m.lazyOffset += m.numberWidth() * 4
 	// log.Debugf("record header: %#v", recordHeader)
 	if int(recordHeader.NumEntries) != m.numEntries {
 		// The number of blocks does NOT need to be equal the number of keyword blocks. Big-endian.
 		// But the number of entries should be EQUAL to keyword_sect.num_entries. Big-endian.
-		log.Fatalf("the num of entries does not match")
+		return fmt.Errorf("%w: the num of entries does not match, %d in record section, %d in keyword section", ErrCorrupt, recordHeader.NumEntries, m.numEntries)
 	}
-	if recordHeader.IndexLen != recordHeader.NumBlocks*16 {
-		log.Fatalf("the index len violates its definition, check the MDX file please")
+	if recordHeader.NumBlocks > uint64(m.fileSize) || recordHeader.IndexLen != recordHeader.NumBlocks*2*uint64(m.numberWidth()) {
+		return fmt.Errorf("%w: the index len violates its definition, check the MDX file please", ErrCorrupt)
 	}
 	m.recordHeader = recordHeader
 
@@ -558,17 +869,28 @@ func (m *MDict) decodeRecordSection(fd io.Reader, lazy bool) error {
 	totalDecomp := 0
 	for i := uint64(0); i < recordHeader.NumBlocks; i++ {
 		// log.Debugf("decoding [%d]th records sizes", i)
-		if err := binary.Read(fd, binary.BigEndian, &records[i]); err != nil {
+		var err error
+		if records[i].CompSize, err = m.readNumber(fd); err != nil {
 			return err
 		}
-		m.lazyOffset += 8 * 2
+		if records[i].DecompSize, err = m.readNumber(fd); err != nil {
+			return err
+		}
+		m.lazyOffset += m.numberWidth() * 2
+		records[i].compOffset = uint64(total)
+		records[i].decompOffset = uint64(totalDecomp)
 		total += int(records[i].CompSize)
 		totalDecomp += int(records[i].DecompSize)
 	}
 	if total != int(recordHeader.BlocksLen) {
-		log.Fatalf("the block len does not match")
+		return fmt.Errorf("%w: the block len does not match", ErrCorrupt)
 	}
 	m.recordBlockSizes = records
+	cacheBlocks := m.CacheBlocks
+	if cacheBlocks == 0 {
+		cacheBlocks = DefaultCacheBlocks
+	}
+	m.recordCache = newLRU[[]byte](cacheBlocks)
 	log.Debugf("decodeRecordSection: %d<-%d", len(m.recordBlockSizes), len(records))
 	m.records = make([]byte, 0, totalDecomp)
 	if lazy { // FIXME: xx
@@ -580,9 +902,9 @@ func (m *MDict) decodeRecordSection(fd io.Reader, lazy bool) error {
 		if err := binary.Read(fd, binary.BigEndian, compressed); err != nil {
 			return err
 		}
-		decompressed := decompress(compressed[:4], compressed[4:8], compressed[8:])
-		if len(decompressed) != int(records[i].DecompSize) {
-			log.Fatalf("decompressed length does not equal to expected")
+		decompressed, err := decompressBlock(compressed, records[i].DecompSize)
+		if err != nil {
+			return fmt.Errorf("record block %d: %w", i, err)
 		}
 		m.records = append(m.records, decompressed...)
 	}
@@ -609,75 +931,61 @@ func keywordIndexDecrypt(data []byte) []byte {
 	return x
 }
 
-func decompress(compType []byte, checksum []byte, before []byte) []byte {
+// decompressBlock decompresses a key/record block, which starts with the compression type and the checksum,
+// size is the expected decompressed size.
+func decompressBlock(block []byte, size uint64) ([]byte, error) {
+	if len(block) < 8 {
+		return nil, fmt.Errorf("%w: the block is too short: %d bytes", ErrCorrupt, len(block))
+	}
+	decompressed, err := decompress(block[:4], block[4:8], block[8:])
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) != int(size) {
+		return nil, fmt.Errorf("%w: decompressed length %d does not equal to expected %d", ErrCorrupt, len(decompressed), size)
+	}
+	return decompressed, nil
+}
+
+func decompress(compType []byte, checksum []byte, before []byte) ([]byte, error) {
 	// log.Debugf("type: %v, checksum: %v", compType, checksum)
 	decompressed := bytes.NewBuffer([]byte{})
 	in := bytes.NewReader(before)
 	switch compType[0] {
 	case 0: // uncompressed, do nothing
 		io.Copy(decompressed, in)
-	case 1: // TODO: lzo compressed
+	case 1: // lzo compressed
+		if out, err := lzo1xDecompress(before, 0); err != nil {
+			return nil, fmt.Errorf("%w: lzo decompress err: %v", ErrCorrupt, err)
+		} else {
+			decompressed.Write(out)
+		}
 	case 2: // zlib compressed
 		if r, err := zlib.NewReader(in); err != nil {
-			log.Fatalf("zlib decompress err: %v", err)
+			return nil, fmt.Errorf("%w: zlib decompress err: %v", ErrCorrupt, err)
 		} else {
-			io.Copy(decompressed, r)
+			_, err := io.Copy(decompressed, r)
 			r.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%w: zlib decompress err: %v", ErrCorrupt, err)
+			}
 		}
+	default:
+		return nil, fmt.Errorf("%w: unknown compression type %v", ErrCorrupt, compType[0])
 	}
 	res := decompressed.Bytes()
 	if adler32.Checksum(res) != binary.BigEndian.Uint32(checksum) {
-		log.Fatalf("checksum not match for decompress! expected: %v", binary.BigEndian.Uint32(checksum))
+		return nil, fmt.Errorf("%w: checksum not match for decompress! expected: %v", ErrChecksum, binary.BigEndian.Uint32(checksum))
 	}
-	return res
+	return res, nil
 }
 
+// DumpData dumps all the resources in the MDD file to util.TmpDir(), see DumpDataTo.
 func (m *MDict) DumpData() error {
-	if m.t != ".mdd" {
-		return fmt.Errorf("The dict should be the MDX file, not %v", m.t)
-	}
 	bar := progressbar.Default(int64(m.numEntries), fmt.Sprintf("dumping mdd entries [%s%s]", m.header.Title, m.t))
-	start := time.Now()
-	defer func() {
-		log.Debugf("dump data cost: %v", time.Since(start))
-	}()
-	m.dumpKeys()
-	total := 0
-	for i, k := range m.keys {
-		fname := m.decodeString(k.key)
-		if len(fname) > 0 {
-			// strip the leading "\"
-			r, size := utf8.DecodeRuneInString(fname)
-			if r != '\\' {
-				log.Warnf("illegal fname: %q", fname)
-				continue
-			}
-			fname = fname[size:]
-			fname = strings.ReplaceAll(fname, "\\", "/")
-			log.Tracef("fname: %q, %q", fname, filepath.Dir(fname))
-		}
-		path := filepath.Join(util.TmpDir(), filepath.Dir(fname))
-		if err := os.MkdirAll(path, 0o755); err != nil {
-			log.Fatalf("make dir for %v err: %v", path, err)
-		}
-		fname = filepath.Join(util.TmpDir(), fname)
-		if file, err := os.Create(fname); err != nil {
-			log.Fatalf("open %v err: %v", fname, err)
-		} else {
-			n, err := file.Write(m.ReadAtOffset(i))
-			log.Tracef("DumpData [%d] to file: %v, n: %v, err: %v", i, fname, n, err)
-			file.Close()
-		}
-
-		total += 1
-		bar.Add(1)
-	}
-	if total != m.numEntries {
-		return fmt.Errorf("the keys not suffice")
-	}
-	runtime.GC()
-	debug.FreeOSMemory()
-	log.Infof("[after DumpData]FreeOSMemory...")
-
-	return nil
+	return m.DumpDataTo(util.TmpDir(), ExportOptions{
+		Progress: func(done, total int) {
+			bar.Set(done)
+		},
+	})
 }
@@ -0,0 +1,52 @@
+package decoder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceKey normalizes path to the form of the keys in MDD files, it's case-insensitive,
+// e.g. "img/A.png", "\img\a.png" and "/img/a.png" are all normalized to "\img\a.png".
+func ResourceKey(path string) string {
+	key := strings.ToLower(strings.ReplaceAll(path, "/", `\`))
+	if !strings.HasPrefix(key, `\`) {
+		key = `\` + key
+	}
+	return key
+}
+
+// Resource returns the content of the resource at path in an MDD file, see ResourceKey for the path.
+// ErrNotFound is returned if there is no such resource.
+func (m *MDict) Resource(path string) ([]byte, error) {
+	if m.t != ".mdd" {
+		return nil, fmt.Errorf("resources are only in MDD files, not %v", m.t)
+	}
+	key := ResourceKey(path)
+	var index int
+	if m.Lookup == LookupIndex {
+		indexes, err := m.searchIndexFunc(key, func(k string) bool { return ResourceKey(k) == key })
+		if err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, path)
+		}
+		index = indexes[0]
+	} else {
+		m.resOnce.Do(func() {
+			m.resources = make(map[string]int, len(m.keys))
+			for i, k := range m.keys {
+				key := ResourceKey(m.decodeString(k.key))
+				if _, ok := m.resources[key]; !ok {
+					m.resources[key] = i
+				}
+			}
+		})
+		i, ok := m.resources[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, path)
+		}
+		index = i
+	}
+	return m.ReadAtOffset(index)
+}
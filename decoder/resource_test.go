@@ -0,0 +1,53 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResourceKey(t *testing.T) {
+	for _, path := range []string{`\img\a.png`, `img/A.png`, `/img/a.PNG`, `\IMG/a.png`} {
+		assert.Equal(t, `\img\a.png`, ResourceKey(path), path)
+	}
+}
+
+func Test_Resource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"US_doctor1.mp3": bytes.Repeat([]byte("ID3"), 500),
+		"img/Apple.png":  bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 300),
+		"a.css":          []byte("b { color: red; }"),
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		assert.Nil(t, os.WriteFile(name, data, 0o644))
+	}
+	e := Encoder{RecordBlockSize: 512, KeyBlockSize: 16}
+	var out bytes.Buffer
+	assert.Nil(t, e.WriteMDD(&out, dir))
+	name := filepath.Join(t.TempDir(), "test.mdd")
+	assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+
+	for _, lookup := range []LookupMode{LookupKeymap, LookupIndex} {
+		m := MDict{Lookup: lookup}
+		assert.Nil(t, m.Decode(name, true))
+		for path, want := range map[string]string{
+			"US_doctor1.mp3": "US_doctor1.mp3",
+			"us_doctor1.mp3": "US_doctor1.mp3",
+			`\img\Apple.png`: "img/Apple.png",
+			"img/apple.png":  "img/Apple.png",
+			"/a.css":         "a.css",
+		} {
+			data, err := m.Resource(path)
+			assert.Nil(t, err, path)
+			assert.Equal(t, files[want], data, path)
+		}
+		_, err := m.Resource("img/banana.png")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+}
@@ -1,6 +1,74 @@
 package decoder
 
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// salsa20 encrypts/decrypts data with the Salsa20 stream cipher, as it's just an XOR with the key stream.
+// The key can be 16 or 32 bytes, rounds is 8 for MDX files, i.e. Salsa20/8.
+// Refer to https://cr.yp.to/snuffle/spec.pdf
 func salsa20(data []byte, key []byte, iv [8]byte, rounds int) []byte {
-	// TODO: pure go salsa20 implementation
-	return nil
+	var constants [4]uint32
+	var k [8]uint32
+	switch len(key) {
+	case 16:
+		constants = [4]uint32{0x61707865, 0x3120646e, 0x79622d36, 0x6b206574} // "expand 16-byte k"
+		for i := 0; i < 4; i++ {
+			k[i] = binary.LittleEndian.Uint32(key[4*i:])
+			k[i+4] = k[i]
+		}
+	case 32:
+		constants = [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574} // "expand 32-byte k"
+		for i := 0; i < 8; i++ {
+			k[i] = binary.LittleEndian.Uint32(key[4*i:])
+		}
+	default:
+		panic("salsa20: the key should be 16 or 32 bytes")
+	}
+
+	var state [16]uint32
+	state[0], state[5], state[10], state[15] = constants[0], constants[1], constants[2], constants[3]
+	state[1], state[2], state[3], state[4] = k[0], k[1], k[2], k[3]
+	state[11], state[12], state[13], state[14] = k[4], k[5], k[6], k[7]
+	state[6] = binary.LittleEndian.Uint32(iv[0:])
+	state[7] = binary.LittleEndian.Uint32(iv[4:])
+
+	res := make([]byte, len(data))
+	var stream [64]byte
+	for counter, p := uint64(0), 0; p < len(data); counter, p = counter+1, p+64 {
+		state[8] = uint32(counter)
+		state[9] = uint32(counter >> 32)
+		salsa20Block(&stream, &state, rounds)
+		for i := 0; i < 64 && p+i < len(data); i++ {
+			res[p+i] = data[p+i] ^ stream[i]
+		}
+	}
+	return res
+}
+
+// salsa20Block computes one 64-byte block of the key stream from the state.
+func salsa20Block(out *[64]byte, in *[16]uint32, rounds int) {
+	x := *in
+	quarter := func(a, b, c, d int) {
+		x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+		x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+		x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+		x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+	}
+	for i := 0; i < rounds; i += 2 {
+		// column round
+		quarter(0, 4, 8, 12)
+		quarter(5, 9, 13, 1)
+		quarter(10, 14, 2, 6)
+		quarter(15, 3, 7, 11)
+		// row round
+		quarter(0, 1, 2, 3)
+		quarter(5, 6, 7, 4)
+		quarter(10, 11, 8, 9)
+		quarter(15, 12, 13, 14)
+	}
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+in[i])
+	}
 }
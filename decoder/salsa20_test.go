@@ -0,0 +1,49 @@
+package decoder
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_salsa20(t *testing.T) {
+	// Salsa20/8 with a 16-byte key, as it's used in MDX files.
+	res := salsa20(make([]byte, 32), []byte("0123456789abcdef"), [8]byte{}, 8)
+	assert.Equal(t, "00f29ffefe7368fab4d7bffe607f075903e279efe04a916e64216920fcbf390a", hex.EncodeToString(res))
+
+	// Salsa20/20 with a 32-byte key, the same as golang.org/x/crypto/salsa20.
+	res = salsa20(make([]byte, 16), []byte("0123456789abcdef0123456789abcdef"), [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, 20)
+	assert.Equal(t, "77208811a1c04983e563d0f16f97200b", hex.EncodeToString(res))
+
+	// It's symmetric, and works across block boundaries.
+	plain := []byte("The quick brown fox jumps over the lazy dog, and the lazy dog jumps over the quick brown fox.")
+	key := []byte("fedcba9876543210")
+	assert.Equal(t, plain, salsa20(salsa20(plain, key, [8]byte{}, 8), key, [8]byte{}, 8))
+}
+
+func Test_DecodeEncryptedHeader(t *testing.T) {
+	const email = "someone@example.com"
+	const regCode = "00112233445566778899aabbccddeeff"
+	entries := [][2]string{{"doctor", "a person who treats sick people"}}
+
+	// encrypt the keyword header the same way as MDict, with the email and the reg code
+	m := MDict{UserID: email, RegCode: regCode}
+	m.header.RegisterBy = "EMail"
+	encrypt := func(raw []byte) []byte {
+		res, err := m.salsaDecrypt(raw)
+		assert.Nil(t, err)
+		return res
+	}
+	b := mdxBuilder{version: "2.0", attrs: `Encrypted="1" RegisterBy="EMail"`, entries: entries, keyHeader: encrypt}
+	name := b.build(t)
+
+	d := MDict{UserID: email, RegCode: regCode}
+	assert.Nil(t, d.Decode(name, true))
+	assert.Equal(t, entries[0][1], mustGet(t, &d, "doctor"))
+
+	// without the passcode
+	assert.NotNil(t, (&MDict{}).Decode(name, true))
+	// with a wrong one
+	assert.NotNil(t, (&MDict{UserID: "nobody@example.com", RegCode: regCode}).Decode(name, true))
+}
@@ -0,0 +1,67 @@
+package decoder
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// style is an entry of the StyleSheet in the header, which a `N` tag in the records expands to.
+type style struct {
+	begin string
+	end   string
+}
+
+var styleTag = regexp.MustCompile("`\\d+`")
+
+// parseStyleSheet parses the StyleSheet attribute, which consists of groups of 3 lines:
+// the number of the style, the beginning HTML and the ending HTML.
+func parseStyleSheet(s string) map[string]style {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	res := make(map[string]style, len(lines)/3)
+	for i := 0; i+2 < len(lines); i += 3 {
+		res[strings.TrimSpace(lines[i])] = style{lines[i+1], lines[i+2]}
+	}
+	return res
+}
+
+// substituteStyle expands the `N` tags in def, the text after a tag is wrapped by the begin/end of its style,
+// the same as readmdict does. Unknown tags are kept as they are.
+func (m *MDict) substituteStyle(def string) string {
+	tags := styleTag.FindAllStringIndex(def, -1)
+	if len(tags) == 0 {
+		return def
+	}
+	var b strings.Builder
+	b.WriteString(def[:tags[0][0]])
+	for i, tag := range tags {
+		end := len(def)
+		if i+1 < len(tags) {
+			end = tags[i+1][0]
+		}
+		text := def[tag[1]:end]
+		s, ok := m.styles[def[tag[0]+1:tag[1]-1]]
+		if !ok {
+			b.WriteString(def[tag[0]:end])
+			continue
+		}
+		if strings.HasSuffix(text, "\n") {
+			b.WriteString(s.begin + strings.TrimRightFunc(text, unicode.IsSpace) + s.end + "\r\n")
+		} else {
+			b.WriteString(s.begin + text + s.end)
+		}
+	}
+	return b.String()
+}
+
+// styled applies the StyleSheet to a record of the MDX file, if it's enabled.
+func (m *MDict) styled(record []byte) []byte {
+	if !m.StyleSheet || len(m.styles) == 0 || m.t != ".mdx" {
+		return record
+	}
+	def := m.decodeString(record)
+	if !styleTag.MatchString(def) {
+		return record
+	}
+	return m.encodeString(m.substituteStyle(def))
+}
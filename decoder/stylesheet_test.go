@@ -0,0 +1,43 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseStyleSheet(t *testing.T) {
+	styles := parseStyleSheet("1\r\n<b>\r\n</b>\r\n2\r\n<i>\r\n</i>\r\n3\r\n<br>")
+	assert.Equal(t, map[string]style{"1": {"<b>", "</b>"}, "2": {"<i>", "</i>"}}, styles)
+}
+
+func Test_StyleSheet(t *testing.T) {
+	dict := map[string]string{
+		"doctor": "`1`doctor`2` a person who treats sick people\r\n`3`",
+		"plain":  "no styles at all",
+		"broken": "`9`unknown style",
+	}
+	for _, encoding := range []string{"UTF-8", "UTF-16"} {
+		e := Encoder{Header: Header{Encoding: encoding, StyleSheet: "1\n<b>\n</b>\n2\n<i>\n</i>\n3\n\n"}}
+		var out bytes.Buffer
+		assert.Nil(t, e.WriteMDX(&out, dict))
+		name := filepath.Join(t.TempDir(), "styled.mdx")
+		assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+
+		m := MDict{StyleSheet: true}
+		assert.Nil(t, m.Decode(name, true))
+		assert.Equal(t, "<b>doctor</b><i> a person who treats sick people</i>\r\n", mustGet(t, &m, "doctor"))
+		assert.Equal(t, "no styles at all", mustGet(t, &m, "plain"))
+		assert.Equal(t, "`9`unknown style", mustGet(t, &m, "broken"))
+		d, err := m.DumpDict()
+		assert.Nil(t, err)
+		assert.Equal(t, "<b>doctor</b><i> a person who treats sick people</i>\r\n", d["doctor"])
+
+		raw := MDict{}
+		assert.Nil(t, raw.Decode(name, true))
+		assert.Equal(t, dict["doctor"], mustGet(t, &raw, "doctor"))
+	}
+}
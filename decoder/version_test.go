@@ -0,0 +1,201 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mdxBuilder writes a tiny, uncompressed MDX file by hand.
+// It is independent from the decoder, so that the on-disk layout of different engine versions can be tested.
+type mdxBuilder struct {
+	version   string
+	attrs     string // extra header attributes
+	entries   [][2]string
+	blocks    func(raw []byte) []byte // packs a key/record block, uncompressed by default
+	keyHeader func(raw []byte) []byte // encrypts the keyword section header, if not nil
+
+	keysPerBlock    int // all the keys are in one block if 0
+	recordBlockSize int // all the records are in one block if 0
+}
+
+func (b *mdxBuilder) v2() bool {
+	return b.version >= "2.0"
+}
+
+func (b *mdxBuilder) number(w *bytes.Buffer, n int) {
+	if b.v2() {
+		binary.Write(w, binary.BigEndian, uint64(n))
+	} else {
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
+
+func (b *mdxBuilder) block(raw []byte) []byte {
+	if b.blocks != nil {
+		return b.blocks(raw)
+	}
+	var w bytes.Buffer
+	w.Write([]byte{0, 0, 0, 0})
+	binary.Write(&w, binary.BigEndian, adler32.Checksum(raw))
+	w.Write(raw)
+	return w.Bytes()
+}
+
+func (b *mdxBuilder) build(t *testing.T) string {
+	var out bytes.Buffer
+	// header
+	xml := fmt.Sprintf(`<Dictionary GeneratedByEngineVersion="%s" RequiredEngineVersion="%s" Encoding="UTF-8" Format="Html" Title="test" %s/>`+"\r\n\x00",
+		b.version, b.version, b.attrs)
+	units := utf16.Encode([]rune(xml))
+	headerBytes := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(headerBytes[2*i:], u)
+	}
+	binary.Write(&out, binary.BigEndian, uint32(len(headerBytes)))
+	out.Write(headerBytes)
+	binary.Write(&out, binary.LittleEndian, adler32.Checksum(headerBytes))
+
+	// key blocks and record blocks
+	chunk := func(n, size int) (res [][2]int) {
+		if size <= 0 {
+			size = n
+		}
+		for i := 0; i < n; i += size {
+			j := i + size
+			if j > n {
+				j = n
+			}
+			res = append(res, [2]int{i, j})
+		}
+		return res
+	}
+	var records bytes.Buffer
+	offsets := make([]int, len(b.entries))
+	for i, e := range b.entries {
+		offsets[i] = records.Len()
+		records.WriteString(e[1])
+	}
+	var index, keyBlocks bytes.Buffer
+	keyChunks := chunk(len(b.entries), b.keysPerBlock)
+	for _, c := range keyChunks {
+		var keys bytes.Buffer
+		for i := c[0]; i < c[1]; i++ {
+			b.number(&keys, offsets[i])
+			keys.WriteString(b.entries[i][0])
+			keys.WriteByte(0)
+		}
+		keyBlock := b.block(keys.Bytes())
+		keyBlocks.Write(keyBlock)
+
+		b.number(&index, c[1]-c[0])
+		for _, w := range []string{b.entries[c[0]][0], b.entries[c[1]-1][0]} {
+			if b.v2() {
+				binary.Write(&index, binary.BigEndian, uint16(len(w)))
+				index.WriteString(w)
+				index.WriteByte(0)
+			} else {
+				index.WriteByte(uint8(len(w)))
+				index.WriteString(w)
+			}
+		}
+		b.number(&index, len(keyBlock))
+		b.number(&index, keys.Len())
+	}
+	keyIndex := index.Bytes()
+	if b.v2() {
+		keyIndex = b.block(keyIndex)
+	}
+
+	// keyword section
+	var kh bytes.Buffer
+	b.number(&kh, len(keyChunks))
+	b.number(&kh, len(b.entries))
+	if b.v2() {
+		b.number(&kh, index.Len())
+	}
+	b.number(&kh, len(keyIndex))
+	b.number(&kh, keyBlocks.Len())
+	if b.keyHeader != nil {
+		out.Write(b.keyHeader(kh.Bytes()))
+	} else {
+		out.Write(kh.Bytes())
+	}
+	if b.v2() {
+		binary.Write(&out, binary.BigEndian, adler32.Checksum(kh.Bytes()))
+	}
+	out.Write(keyIndex)
+	out.Write(keyBlocks.Bytes())
+
+	// record section, the blocks are split by bytes, so a record may span two blocks
+	recordChunks := chunk(records.Len(), b.recordBlockSize)
+	var recordBlocks bytes.Buffer
+	var recordIndex bytes.Buffer
+	for _, c := range recordChunks {
+		block := b.block(records.Bytes()[c[0]:c[1]])
+		recordBlocks.Write(block)
+		b.number(&recordIndex, len(block))
+		b.number(&recordIndex, c[1]-c[0])
+	}
+	b.number(&out, len(recordChunks))
+	b.number(&out, len(b.entries))
+	b.number(&out, recordIndex.Len())
+	b.number(&out, recordBlocks.Len())
+	out.Write(recordIndex.Bytes())
+	out.Write(recordBlocks.Bytes())
+
+	name := filepath.Join(t.TempDir(), "test.mdx")
+	if err := os.WriteFile(name, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func mustGet(t *testing.T, m *MDict, word string) string {
+	t.Helper()
+	def, err := m.Get(word)
+	assert.Nil(t, err)
+	return def
+}
+
+func mustGetAll(t *testing.T, m *MDict, word string) []string {
+	t.Helper()
+	defs, err := m.GetAll(word)
+	assert.Nil(t, err)
+	return defs
+}
+
+func Test_DecodeEngineVersions(t *testing.T) {
+	entries := [][2]string{
+		{"apple", "<b>apple</b> a fruit"},
+		{"doctor", "a person who treats sick people"},
+		{"zoo", "a place where animals are kept"},
+	}
+	for _, version := range []string{"1.2", "2.0"} {
+		t.Run(version, func(t *testing.T) {
+			b := mdxBuilder{version: version, attrs: `Encrypted="No"`, entries: entries}
+			m := MDict{}
+			assert.Nil(t, m.Decode(b.build(t), true))
+			assert.ElementsMatch(t, []string{"apple", "doctor", "zoo"}, m.Keys())
+			for _, e := range entries {
+				assert.Equal(t, e[1], mustGet(t, &m, e[0]))
+			}
+			dict, err := m.DumpDict()
+			assert.Nil(t, err)
+			assert.Equal(t, len(entries), len(dict))
+		})
+	}
+}
+
+func Test_DecodeUnsupportedVersion(t *testing.T) {
+	b := mdxBuilder{version: "3.0", entries: [][2]string{{"a", "b"}}}
+	m := MDict{}
+	assert.NotNil(t, m.Decode(b.build(t), true))
+}
@@ -0,0 +1,267 @@
+// Package dsl reads ABBYY Lingvo DSL dictionaries (.dsl or the gzipped .dsl.dz),
+// and converts their markup to HTML and markdown.
+package dsl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Info is the metadata in the header of a DSL file.
+type Info struct {
+	Name             string `json:"name"`
+	IndexLanguage    string `json:"index_language,omitempty"`
+	ContentsLanguage string `json:"contents_language,omitempty"`
+	SourceCodePage   string `json:"source_code_page,omitempty"`
+	Cards            int    `json:"cards"`
+}
+
+// Dict is a DSL dictionary loaded in memory.
+type Dict struct {
+	info  Info
+	cards []string         // the text of the cards, i.e. the headword lines followed by the indented body lines
+	keys  map[string][]int // the lowercase headword -> indexes of cards
+	words []string         // all the keys of the headwords, in the order of appearance
+}
+
+// Open reads the DSL file name, which is decompressed if it ends with ".dz".
+func Open(name string) (*Dict, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(name, ".dz") {
+		z, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompress %v err: %v", name, err)
+		}
+		var b bytes.Buffer
+		if _, err := b.ReadFrom(z); err != nil {
+			return nil, fmt.Errorf("decompress %v err: %v", name, err)
+		}
+		data = b.Bytes()
+	}
+	text, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode %v err: %v", name, err)
+	}
+	return Parse(text), nil
+}
+
+// decode converts data to UTF-8, it's UTF-16 if there is a BOM or it looks like so, UTF-8 otherwise.
+func decode(data []byte) (string, error) {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		order, data = binary.LittleEndian, data[2:]
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		order, data = binary.BigEndian, data[2:]
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		data = data[3:]
+	case len(data) >= 2 && data[0] == '#' && data[1] == 0:
+		order = binary.LittleEndian
+	case len(data) >= 2 && data[0] == 0 && data[1] == '#':
+		order = binary.BigEndian
+	}
+	if order == nil {
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("unsupported encoding, only UTF-8 and UTF-16 are supported")
+		}
+		return string(data), nil
+	}
+	if len(data)%2 != 0 {
+		return "", fmt.Errorf("odd length of UTF-16 text")
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// Parse parses the text of a DSL file.
+func Parse(text string) *Dict {
+	d := &Dict{keys: make(map[string][]int)}
+	text = stripComments(strings.ReplaceAll(text, "\r\n", "\n"))
+	var headwords, body []string
+	flush := func() {
+		if len(headwords) > 0 && len(body) > 0 {
+			i := len(d.cards)
+			d.cards = append(d.cards, strings.Join(append(headwords, body...), "\n"))
+			for _, h := range headwords {
+				for _, k := range Keys(h) {
+					lk := strings.ToLower(k)
+					n := len(d.keys[lk])
+					if n == 0 {
+						// the keys differing only in case are looked up the same way, so keep one of them
+						d.words = append(d.words, k)
+					}
+					if n == 0 || d.keys[lk][n-1] != i {
+						d.keys[lk] = append(d.keys[lk], i)
+					}
+				}
+			}
+		}
+		headwords, body = nil, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "#"):
+			d.header(line)
+		case strings.TrimSpace(line) == "":
+			// an empty line between cards
+		case line[0] == ' ' || line[0] == '\t':
+			body = append(body, line)
+		default:
+			// a headword, which starts a new card unless it's an alternative headword of the current one
+			if len(body) > 0 {
+				flush()
+			}
+			headwords = append(headwords, line)
+		}
+	}
+	flush()
+	d.info.Cards = len(d.cards)
+	return d
+}
+
+func (d *Dict) header(line string) {
+	k, v, _ := strings.Cut(line[1:], " ")
+	v = strings.Trim(strings.TrimSpace(v), `"`)
+	switch strings.ToUpper(k) {
+	case "NAME":
+		d.info.Name = v
+	case "INDEX_LANGUAGE":
+		d.info.IndexLanguage = v
+	case "CONTENTS_LANGUAGE":
+		d.info.ContentsLanguage = v
+	case "SOURCE_CODE_PAGE":
+		d.info.SourceCodePage = v
+	}
+}
+
+// stripComments removes the {{comments}}, which may span lines.
+func stripComments(text string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(text, "{{")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(text[i+2:], "}}")
+		if j < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		text = text[i+2+j+2:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// Headword returns how the headword line h is displayed, i.e. without the braces and the escapes.
+// E.g. "{to }go" is "to go", "colo(u)r" is "colo(u)r".
+func Headword(h string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range strings.TrimSpace(h) {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '{' || r == '}':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Keys returns the words the headword line h is indexed with, the {unsorted parts} are dropped,
+// and the (optional parts) are expanded, e.g. "{to }colo(u)r" is indexed with "colour" and "color".
+func Keys(h string) []string {
+	keys := []string{""}
+	braces := 0
+	var optional []string // the keys before the current optional part
+	escaped := false
+	add := func(r rune) {
+		for i := range keys {
+			keys[i] += string(r)
+		}
+	}
+	for _, r := range strings.TrimSpace(h) {
+		switch {
+		case escaped:
+			escaped = false
+			if braces == 0 {
+				add(r)
+			}
+		case r == '\\':
+			escaped = true
+		case r == '{':
+			braces++
+		case r == '}':
+			if braces > 0 {
+				braces--
+			}
+		case braces > 0:
+		case r == '(' && optional == nil:
+			optional = append([]string(nil), keys...)
+		case r == ')' && optional != nil:
+			keys = append(keys, optional...)
+			optional = nil
+		default:
+			add(r)
+		}
+	}
+	if optional != nil {
+		keys = append(keys, optional...)
+	}
+	res := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		k = strings.Join(strings.Fields(k), " ")
+		if k != "" && !seen[k] {
+			seen[k] = true
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// Info returns the metadata of d.
+func (d *Dict) Info() Info {
+	return d.info
+}
+
+// Keys returns the keys of all the headwords, including the alternative ones, see Keys.
+func (d *Dict) Keys() []string {
+	return d.words
+}
+
+// Get returns the first card of word, see GetAll.
+func (d *Dict) Get(word string) string {
+	if cards := d.GetAll(word); len(cards) > 0 {
+		return cards[0]
+	}
+	return ""
+}
+
+// GetAll returns all the cards of word case-insensitively, in the DSL markup,
+// i.e. the headword lines followed by the indented body lines, see ToHTML and ToMarkdown.
+func (d *Dict) GetAll(word string) []string {
+	indexes := d.keys[strings.ToLower(strings.Join(strings.Fields(word), " "))]
+	res := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		res = append(res, d.cards[i])
+	}
+	return res
+}
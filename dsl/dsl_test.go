@@ -0,0 +1,94 @@
+package dsl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDSL = `#NAME "Test Dictionary"
+#INDEX_LANGUAGE "English"
+#CONTENTS_LANGUAGE "Russian"
+{{ a comment
+   across lines }}
+apple
+	[m1][b]1.[/b] [trn]яблоко[/trn][/m]
+	[m2][ex]an ~ a day[/ex][/m]
+
+colo(u)r
+{to }paint
+	[m1][trn]цвет[/trn], see <<hue>>[/m]
+Apple
+	[p]n[/p] a company
+`
+
+func Test_Parse(t *testing.T) {
+	d := Parse(testDSL)
+	assert.Equal(t, Info{Name: "Test Dictionary", IndexLanguage: "English", ContentsLanguage: "Russian", Cards: 3}, d.Info())
+	assert.Equal(t, []string{"apple", "colour", "color", "paint"}, d.Keys())
+
+	assert.Equal(t, []string{
+		"apple\n\t[m1][b]1.[/b] [trn]яблоко[/trn][/m]\n\t[m2][ex]an ~ a day[/ex][/m]",
+		"Apple\n\t[p]n[/p] a company",
+	}, d.GetAll("APPLE"))
+	card := "colo(u)r\n{to }paint\n\t[m1][trn]цвет[/trn], see <<hue>>[/m]"
+	assert.Equal(t, card, d.Get("color"))
+	assert.Equal(t, card, d.Get("Colour"))
+	assert.Equal(t, card, d.Get("paint"))
+	assert.Equal(t, "", d.Get("to paint"))
+	assert.Equal(t, "", d.Get("a comment"))
+}
+
+func Test_Keys(t *testing.T) {
+	for h, want := range map[string][]string{
+		"go":                {"go"},
+		"{to }go":           {"go"},
+		"colo(u)r":          {"colour", "color"},
+		"  a  b ":           {"a b"},
+		`AT\&T \(company\)`: {"AT&T (company)"},
+		"{[i]}word{[/i]}":   {"word"},
+	} {
+		assert.Equal(t, want, Keys(h), h)
+	}
+	assert.Equal(t, "to go", Headword("{to }go"))
+	assert.Equal(t, "(company)", Headword(`\(company\)`))
+}
+
+func Test_Open(t *testing.T) {
+	dir := t.TempDir()
+	units := utf16.Encode([]rune(testDSL))
+	var le bytes.Buffer
+	le.Write([]byte{0xff, 0xfe})
+	binary.Write(&le, binary.LittleEndian, units)
+	var gz bytes.Buffer
+	z := gzip.NewWriter(&gz)
+	z.Write(le.Bytes())
+	assert.Nil(t, z.Close())
+	var be bytes.Buffer
+	binary.Write(&be, binary.BigEndian, units)
+
+	for name, data := range map[string][]byte{
+		"utf8.dsl":     []byte("\xef\xbb\xbf" + testDSL),
+		"utf16le.dsl":  le.Bytes(),
+		"utf16be.dsl":  be.Bytes(),
+		"utf16.dsl.dz": gz.Bytes(),
+	} {
+		name = filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(name, data, 0o644))
+		d, err := Open(name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, "Test Dictionary", d.Info().Name, name)
+		assert.Len(t, d.GetAll("apple"), 2, name)
+	}
+
+	name := filepath.Join(dir, "latin1.dsl")
+	assert.Nil(t, os.WriteFile(name, []byte("#NAME \"caf\xe9\"\n"), 0o644))
+	_, err := Open(name)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,270 @@
+package dsl
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// token is a piece of text, or a tag such as "[m1]", "[/trn]" and "[c red]".
+type token struct {
+	text    string
+	tag     string // empty for a piece of text
+	attr    string // e.g. "red" of "[c red]"
+	closing bool
+}
+
+// tokenize splits a line of the DSL markup into text and tags, the escapes are resolved,
+// and "<<word>>" is taken as "[ref]word[/ref]".
+func tokenize(line string) []token {
+	var res []token
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			res = append(res, token{text: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			text.WriteByte(line[i])
+		case c == '[':
+			j := strings.IndexByte(line[i:], ']')
+			if j < 0 {
+				text.WriteByte(c)
+				continue
+			}
+			flush()
+			t := token{}
+			name := line[i+1 : i+j]
+			if strings.HasPrefix(name, "/") {
+				t.closing, name = true, name[1:]
+			}
+			t.tag, t.attr, _ = strings.Cut(name, " ")
+			t.attr = strings.TrimSpace(t.attr)
+			res = append(res, t)
+			i += j
+		case strings.HasPrefix(line[i:], "<<"):
+			j := strings.Index(line[i+2:], ">>")
+			if j < 0 {
+				text.WriteByte(c)
+				continue
+			}
+			flush()
+			res = append(res, token{tag: "ref"}, token{text: line[i+2 : i+2+j]}, token{tag: "ref", closing: true})
+			i += 2 + j + 1
+		default:
+			text.WriteByte(c)
+		}
+	}
+	flush()
+	return res
+}
+
+// until returns the text of the tokens up to the closing tag, and the index of the closing tag.
+func until(tokens []token, i int, tag string) (string, int) {
+	var b strings.Builder
+	for ; i < len(tokens); i++ {
+		if tokens[i].tag == tag && tokens[i].closing {
+			break
+		}
+		b.WriteString(tokens[i].text)
+	}
+	return b.String(), i
+}
+
+// margin returns the indentation level of "[m]" and "[m1]".."[m9]", -1 if tag is not one of them.
+func margin(tag string) int {
+	if tag == "m" {
+		return 0
+	}
+	if len(tag) == 2 && tag[0] == 'm' && '0' <= tag[1] && tag[1] <= '9' {
+		return int(tag[1] - '0')
+	}
+	return -1
+}
+
+// isImage reports whether the media file of [s] is a picture, the others are taken as sounds.
+func isImage(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg", ".webp":
+		return true
+	}
+	return false
+}
+
+// spans are the tags converted to <span class="dsl_tag"> in HTML.
+var spans = map[string]bool{
+	"trn": true, "!trs": true, "ex": true, "com": true, "*": true, "p": true, "lang": true, "t": true, "'": true,
+}
+
+// card splits the text of a card into the headword lines and the body lines,
+// "~" in the body is replaced with the first headword.
+func card(text string) (headwords []string, body []string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			headwords = append(headwords, Headword(line))
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if len(headwords) > 0 {
+			line = replaceTilde(line, headwords[0])
+		}
+		body = append(body, line)
+	}
+	return headwords, body
+}
+
+// replaceTilde replaces the unescaped "~" in line with headword.
+func replaceTilde(line string, headword string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+		case line[i] == '~':
+			b.WriteString(strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(headword))
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+	return b.String()
+}
+
+// ToHTML converts a card in the DSL markup, see Dict.GetAll, to HTML.
+// The references are converted to "entry://" links, the sounds to "sound://" links
+// and the pictures to <img>, so that render.HTMLRender can handle them like those in MDX files.
+func ToHTML(text string) string {
+	headwords, body := card(text)
+	var b strings.Builder
+	if len(headwords) > 0 {
+		b.WriteString(`<div class="dsl_headwords">`)
+		for i, h := range headwords {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("<b>" + html.EscapeString(h) + "</b>")
+		}
+		b.WriteString("</div>\n")
+	}
+	for _, line := range body {
+		tokens := tokenize(line)
+		indented := len(tokens) > 0 && margin(tokens[0].tag) >= 0 && !tokens[0].closing
+		if !indented {
+			b.WriteString("<div>")
+		}
+		divs := 0
+		for i := 0; i < len(tokens); i++ {
+			t := tokens[i]
+			switch {
+			case t.tag == "":
+				b.WriteString(html.EscapeString(t.text))
+			case margin(t.tag) >= 0:
+				if t.closing {
+					if divs > 0 {
+						b.WriteString("</div>")
+						divs--
+					}
+					continue
+				}
+				fmt.Fprintf(&b, `<div class="dsl_m%d" style="margin-left:%dem">`, margin(t.tag), margin(t.tag))
+				divs++
+			case t.closing:
+				switch t.tag {
+				case "b", "i", "u", "sub", "sup":
+					b.WriteString("</" + t.tag + ">")
+				case "c":
+					b.WriteString("</span>")
+				default:
+					if spans[t.tag] {
+						b.WriteString("</span>")
+					}
+				}
+			case t.tag == "b" || t.tag == "i" || t.tag == "u" || t.tag == "sub" || t.tag == "sup":
+				b.WriteString("<" + t.tag + ">")
+			case t.tag == "c":
+				color := t.attr
+				if color == "" {
+					color = "green"
+				}
+				fmt.Fprintf(&b, `<span style="color:%s">`, html.EscapeString(color))
+			case t.tag == "ref":
+				var word string
+				word, i = until(tokens, i+1, "ref")
+				fmt.Fprintf(&b, `<a href="entry://%s">%s</a>`, url.PathEscape(word), html.EscapeString(word))
+			case t.tag == "url":
+				var link string
+				link, i = until(tokens, i+1, "url")
+				fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(link), html.EscapeString(link))
+			case t.tag == "s":
+				var name string
+				name, i = until(tokens, i+1, "s")
+				if isImage(name) {
+					fmt.Fprintf(&b, `<img src="%s">`, html.EscapeString(name))
+				} else {
+					fmt.Fprintf(&b, `<a href="sound://%s">&#x1f50a;</a>`, html.EscapeString(name))
+				}
+			case spans[t.tag]:
+				class := strings.NewReplacer("!", "", "*", "opt", "'", "stress").Replace(t.tag)
+				fmt.Fprintf(&b, `<span class="dsl_%s">`, class)
+			}
+		}
+		for ; divs > 0; divs-- {
+			b.WriteString("</div>")
+		}
+		if !indented {
+			b.WriteString("</div>")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ToMarkdown converts a card in the DSL markup, see Dict.GetAll, to markdown.
+// The media files are dropped, and the references are kept as plain words.
+func ToMarkdown(text string) string {
+	headwords, body := card(text)
+	var lines []string
+	if len(headwords) > 0 {
+		lines = append(lines, "**"+strings.Join(headwords, ", ")+"**")
+	}
+	for _, line := range body {
+		var b strings.Builder
+		tokens := tokenize(line)
+		indent := 0
+		for i := 0; i < len(tokens); i++ {
+			t := tokens[i]
+			switch {
+			case t.tag == "":
+				b.WriteString(t.text)
+			case margin(t.tag) >= 0:
+				if !t.closing && b.Len() == 0 {
+					indent = margin(t.tag)
+				}
+			case t.tag == "b":
+				b.WriteString("**")
+			case t.tag == "i" || t.tag == "ex" || t.tag == "p":
+				b.WriteString("*")
+			case t.tag == "url" && !t.closing:
+				var link string
+				link, i = until(tokens, i+1, "url")
+				b.WriteString("<" + link + ">")
+			case t.tag == "s" && !t.closing:
+				_, i = until(tokens, i+1, "s")
+			}
+		}
+		if s := strings.TrimSpace(b.String()); s != "" {
+			lines = append(lines, strings.Repeat("  ", indent)+s)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
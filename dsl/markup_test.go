@@ -0,0 +1,44 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ToHTML(t *testing.T) {
+	for _, c := range []struct {
+		card string
+		want string
+	}{
+		{
+			"apple\n\t[m1][b]1.[/b] [trn]яблоко[/trn][/m]\n\t[m2][ex]an ~ a day[/ex][/m]",
+			`<div class="dsl_headwords"><b>apple</b></div>` + "\n" +
+				`<div class="dsl_m1" style="margin-left:1em"><b>1.</b> <span class="dsl_trn">яблоко</span></div>` + "\n" +
+				`<div class="dsl_m2" style="margin-left:2em"><span class="dsl_ex">an apple a day</span></div>` + "\n",
+		},
+		{
+			"{to }go\n\tsee [ref]went[/ref] or <<gone>>, [c red]x[/c] [c]y[/c] [i]1 < 2[/i]",
+			`<div class="dsl_headwords"><b>to go</b></div>` + "\n" +
+				`<div>see <a href="entry://went">went</a> or <a href="entry://gone">gone</a>, ` +
+				`<span style="color:red">x</span> <span style="color:green">y</span> <i>1 &lt; 2</i></div>` + "\n",
+		},
+		{
+			"a\n\t[s]a.wav[/s] [s]pic/a.png[/s] [url]https://example.com[/url]",
+			`<div class="dsl_headwords"><b>a</b></div>` + "\n" +
+				`<div><a href="sound://a.wav">&#x1f50a;</a> <img src="pic/a.png"> <a href="https://example.com">https://example.com</a></div>` + "\n",
+		},
+		{
+			"a\\[1\\]\n\t[m1]\\[not a tag\\] [unknown]x[/unknown] [*]more[/*]",
+			`<div class="dsl_headwords"><b>a[1]</b></div>` + "\n" +
+				`<div class="dsl_m1" style="margin-left:1em">[not a tag] x <span class="dsl_opt">more</span></div>` + "\n",
+		},
+	} {
+		assert.Equal(t, c.want, ToHTML(c.card), c.card)
+	}
+}
+
+func Test_ToMarkdown(t *testing.T) {
+	card := "apple\nApfel\n\t[m1][b]1.[/b] [trn]яблоко[/trn][/m]\n\t[m2][ex]an ~ a day[/ex] [s]a.wav[/s][/m]\n\t<<pear>>"
+	assert.Equal(t, "**apple, Apfel**\n  **1.** яблоко\n    *an apple a day*\npear", ToMarkdown(card))
+}
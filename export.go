@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+	"github.com/ChaosNyaruko/ondict/export"
+	"github.com/ChaosNyaruko/ondict/sources"
+	"github.com/ChaosNyaruko/ondict/util"
+)
+
+// runExport runs "ondict export", which exports an MDX dictionary to other formats.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	name := fs.String("dict", "", "The dictionary to export, the name or the alias in config.json, or the path of an MDX file")
+	format := fs.String("format", "json", "The output format, one of "+strings.Join(export.Formats, ", "))
+	out := fs.String("o", "", "The output file, stdout if empty. For 'stardict', it's the base name of the files, e.g. 'out/oald9' for 'out/oald9.ifo', etc.\nFor 'json', put it as '<name>.json' next to the MDX file, so that ondict loads it much faster.")
+	verbose := fs.Bool("v", false, "Show debug logs")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ondict export -dict <name> [-format %s] [-o <path>]\n", strings.Join(export.Formats, "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+	if *name == "" {
+		fs.Usage()
+		return fmt.Errorf("-dict is required")
+	}
+	if *format == "stardict" && *out == "" {
+		return fmt.Errorf("-o is required for the stardict format")
+	}
+
+	m := &decoder.MDict{Lookup: decoder.LookupIndex}
+	file := strings.TrimSuffix(*name, ".mdx") + ".mdx"
+	if err := sources.LoadConfig(); err != nil {
+		log.Debugf("load config err: %v", err)
+	}
+	if d := findDict(*name); d != nil {
+		file = d.MdxFile + ".mdx"
+		m.UserID, m.RegCode, m.StyleSheet = d.UserID, d.RegCode, d.StyleSheet
+	} else if _, err := os.Stat(file); err != nil {
+		file = filepath.Join(util.DictsPath(), file)
+	}
+	if err := m.Decode(file, true); err != nil {
+		return fmt.Errorf("decode %v err: %w", file, err)
+	}
+	return export.Write(m, *format, *out)
+}
+
+// findDict finds the dictionary named name (its Name or Alias) in the config, see MdxDict.Is.
+func findDict(name string) *sources.MdxDict {
+	for _, d := range *sources.G {
+		if d.Is(name) {
+			return d
+		}
+	}
+	return nil
+}
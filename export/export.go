@@ -0,0 +1,91 @@
+// Package export converts MDX dictionaries to other formats, such as JSON, JSON Lines, TSV and StarDict.
+// The JSON format is the same as the "<name>.json" files loaded by ondict, which are much faster to load.
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+// Formats are all the supported formats.
+var Formats = []string{"json", "jsonl", "tsv", "stardict"}
+
+// Entry is an entry of the dictionary, either a definition or a redirect to another key.
+type Entry struct {
+	Key        string `json:"key"`
+	Definition string `json:"definition,omitempty"`
+	// Link is the target key if it's an "@@@LINK=" redirect.
+	Link string `json:"link,omitempty"`
+}
+
+// Entries reads all the entries of the MDX dictionary m, in the order of the dictionary.
+func Entries(m *decoder.MDict) ([]Entry, error) {
+	var mu sync.Mutex
+	indexes := make(map[int]Entry, m.Info().Entries)
+	err := m.Export(decoder.ExportOptions{}, func(e decoder.Entry) error {
+		def := strings.TrimRight(m.DecodeString(e.Record), "\x00")
+		entry := Entry{Key: e.Key}
+		if target, ok := decoder.LinkTarget(def); ok {
+			entry.Link = target
+		} else {
+			entry.Definition = def
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		indexes[e.Index] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]int, 0, len(indexes))
+	for i := range indexes {
+		keys = append(keys, i)
+	}
+	sort.Ints(keys)
+	res := make([]Entry, 0, len(keys))
+	for _, i := range keys {
+		res = append(res, indexes[i])
+	}
+	return res, nil
+}
+
+// Write exports m in format to out, which is the base name of the files for "stardict",
+// such as "out/oald9" for "out/oald9.ifo", "out/oald9.idx", etc., and a file or "-" for stdout for the others.
+func Write(m *decoder.MDict, format string, out string) error {
+	entries, err := Entries(m)
+	if err != nil {
+		return err
+	}
+	log.Debugf("export %d entries of %v to %v in %v", len(entries), m.Info().Title, out, format)
+	if format == "stardict" {
+		return WriteStarDict(strings.TrimSuffix(out, ".ifo"), m.Info(), entries)
+	}
+	var w io.Writer = os.Stdout
+	if out != "" && out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch format {
+	case "json":
+		return WriteJSON(w, entries)
+	case "jsonl":
+		return WriteJSONL(w, entries)
+	case "tsv":
+		return WriteTSV(w, entries)
+	default:
+		return fmt.Errorf("unknown format %q, it should be one of %v", format, Formats)
+	}
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+func testDict(t *testing.T, encoding string) *decoder.MDict {
+	t.Helper()
+	e := decoder.Encoder{Header: decoder.Header{Title: "Test", Description: "for\ntests", Encoding: encoding}}
+	var out bytes.Buffer
+	assert.Nil(t, e.WriteMDX(&out, map[string]string{
+		"apple":     "<b>apple</b>\ta fruit\x00",
+		"Banana":    "a yellow fruit",
+		"tormenter": "@@@LINK=tormentor\r\n\x00",
+		"tormentor": "someone who torments",
+		"torment":   "@@@LINK=tormenter",
+		"中文":        "Chinese",
+	}))
+	name := filepath.Join(t.TempDir(), "test.mdx")
+	assert.Nil(t, os.WriteFile(name, out.Bytes(), 0o644))
+	m := &decoder.MDict{}
+	assert.Nil(t, m.Decode(name, true))
+	return m
+}
+
+func Test_Entries(t *testing.T) {
+	for _, encoding := range []string{"UTF-8", "UTF-16"} {
+		entries, err := Entries(testDict(t, encoding))
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []Entry{
+			{Key: "apple", Definition: "<b>apple</b>\ta fruit"},
+			{Key: "Banana", Definition: "a yellow fruit"},
+			{Key: "tormenter", Link: "tormentor"},
+			{Key: "tormentor", Definition: "someone who torments"},
+			{Key: "torment", Link: "tormenter"},
+			{Key: "中文", Definition: "Chinese"},
+		}, entries, encoding)
+	}
+}
+
+func Test_WriteText(t *testing.T) {
+	entries := []Entry{
+		{Key: "a", Definition: "<b>x</b>\ty\nz"},
+		{Key: "a", Definition: "again"},
+		{Key: "b", Link: "a"},
+	}
+	var out bytes.Buffer
+	assert.Nil(t, WriteJSON(&out, entries))
+	var m map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &m))
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{"<b>x</b>\ty\nz", "again"}, "b": "@@@LINK=a"}, m)
+
+	out.Reset()
+	assert.Nil(t, WriteJSONL(&out, entries))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	var e Entry
+	assert.Nil(t, json.Unmarshal([]byte(lines[2]), &e))
+	assert.Equal(t, entries[2], e)
+
+	out.Reset()
+	assert.Nil(t, WriteTSV(&out, entries))
+	assert.Equal(t, "a\t<b>x</b>\\ty\\nz\na\tagain\nb\t@@@LINK=a\n", out.String())
+}
+
+func Test_WriteStarDict(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "test")
+	assert.Nil(t, Write(testDict(t, "UTF-16"), "stardict", base))
+
+	ifo, err := os.ReadFile(base + ".ifo")
+	assert.Nil(t, err)
+	assert.Contains(t, string(ifo), "bookname=Test\n")
+	assert.Contains(t, string(ifo), "wordcount=4\n")
+	assert.Contains(t, string(ifo), "synwordcount=2\n")
+	assert.Contains(t, string(ifo), "sametypesequence=h\n")
+	assert.Contains(t, string(ifo), "description=for<br>tests\n")
+
+	dict, err := os.ReadFile(base + ".dict")
+	assert.Nil(t, err)
+	idx, err := os.ReadFile(base + ".idx")
+	assert.Nil(t, err)
+	assert.Contains(t, string(ifo), "idxfilesize="+itoa(len(idx))+"\n")
+	var words []string
+	defs := make(map[string]string)
+	for len(idx) > 0 {
+		i := bytes.IndexByte(idx, 0)
+		word := string(idx[:i])
+		offset, size := binary.BigEndian.Uint32(idx[i+1:]), binary.BigEndian.Uint32(idx[i+5:])
+		words = append(words, word)
+		defs[word] = string(dict[offset : offset+size])
+		idx = idx[i+9:]
+	}
+	assert.Equal(t, []string{"apple", "Banana", "tormentor", "中文"}, words)
+	assert.Equal(t, "a yellow fruit", defs["Banana"])
+
+	syn, err := os.ReadFile(base + ".syn")
+	assert.Nil(t, err)
+	synonyms := make(map[string]string)
+	for len(syn) > 0 {
+		i := bytes.IndexByte(syn, 0)
+		synonyms[string(syn[:i])] = words[binary.BigEndian.Uint32(syn[i+1:])]
+		syn = syn[i+5:]
+	}
+	assert.Equal(t, map[string]string{"tormenter": "tormentor", "torment": "tormentor"}, synonyms)
+}
+
+func Test_stardictLess(t *testing.T) {
+	words := []string{"b", "B", "a", "ab", "A"}
+	for i := range words {
+		for j := range words {
+			if i != j {
+				assert.NotEqual(t, stardictLess(words[i], words[j]), stardictLess(words[j], words[i]))
+			}
+		}
+	}
+	assert.True(t, stardictLess("A", "a"))
+	assert.True(t, stardictLess("a", "B"))
+	assert.True(t, stardictLess("a", "ab"))
+}
+
+func itoa(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
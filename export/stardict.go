@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+// stardictLess is the order of the words in .idx and .syn files,
+// i.e. g_ascii_strcasecmp first, then strcmp for the ties.
+func stardictLess(a, b string) bool {
+	if c := asciiCaseCompare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+func asciiCaseCompare(a, b string) int {
+	lower := func(c byte) byte {
+		if 'A' <= c && c <= 'Z' {
+			return c + 'a' - 'A'
+		}
+		return c
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ca, cb := lower(a[i]), lower(b[i]); ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	return len(a) - len(b)
+}
+
+// WriteStarDict writes the entries as a StarDict dictionary, i.e. base.ifo, base.idx, base.dict and base.syn.
+// The redirects are written to the .syn file as synonyms of their targets.
+func WriteStarDict(base string, info decoder.Info, entries []Entry) error {
+	var words []Entry
+	firstDef := make(map[string]Entry)
+	for _, e := range entries {
+		if e.Link != "" {
+			continue
+		}
+		words = append(words, e)
+		if _, ok := firstDef[e.Key]; !ok {
+			firstDef[e.Key] = e
+		}
+	}
+	sort.SliceStable(words, func(i, j int) bool { return stardictLess(words[i].Key, words[j].Key) })
+
+	// .dict and .idx
+	dict, err := os.Create(base + ".dict")
+	if err != nil {
+		return err
+	}
+	defer dict.Close()
+	dw := bufio.NewWriter(dict)
+	var idx strings.Builder
+	wordIndex := make(map[string]int, len(words))
+	offset := 0
+	for i, e := range words {
+		if _, ok := wordIndex[e.Key]; !ok {
+			wordIndex[e.Key] = i
+		}
+		if _, err := dw.WriteString(e.Definition); err != nil {
+			return err
+		}
+		idx.WriteString(e.Key + "\x00")
+		binary.Write(&idx, binary.BigEndian, uint32(offset))
+		binary.Write(&idx, binary.BigEndian, uint32(len(e.Definition)))
+		offset += len(e.Definition)
+	}
+	if err := dw.Flush(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".idx", []byte(idx.String()), 0o644); err != nil {
+		return err
+	}
+
+	// .syn, the links are followed to the final definitions
+	links := make(map[string]string)
+	for _, e := range entries {
+		if e.Link != "" {
+			if _, ok := links[e.Key]; !ok {
+				links[e.Key] = e.Link
+			}
+		}
+	}
+	type synonym struct {
+		word  string
+		index int
+	}
+	var syns []synonym
+	for _, e := range entries {
+		if e.Link == "" {
+			continue
+		}
+		target := e.Link
+		for depth := 0; depth < decoder.MaxLinkDepth && links[target] != "" && firstDef[target].Key == ""; depth++ {
+			target = links[target]
+		}
+		i, ok := wordIndex[target]
+		if !ok {
+			log.Warnf("the target of %q -> %q is not found, skip it", e.Key, e.Link)
+			continue
+		}
+		syns = append(syns, synonym{e.Key, i})
+	}
+	sort.SliceStable(syns, func(i, j int) bool { return stardictLess(syns[i].word, syns[j].word) })
+	if len(syns) > 0 {
+		var syn strings.Builder
+		for _, s := range syns {
+			syn.WriteString(s.word + "\x00")
+			binary.Write(&syn, binary.BigEndian, uint32(s.index))
+		}
+		if err := os.WriteFile(base+".syn", []byte(syn.String()), 0o644); err != nil {
+			return err
+		}
+	}
+
+	// .ifo
+	typ := "m"
+	if strings.EqualFold(info.Format, "Html") {
+		typ = "h"
+	}
+	title := info.Title
+	if title == "" {
+		title = filepath.Base(base)
+	}
+	oneLine := strings.NewReplacer("\r\n", "<br>", "\n", "<br>", "\r", "")
+	var ifo strings.Builder
+	ifo.WriteString("StarDict's dict ifo file\nversion=3.0.0\n")
+	fmt.Fprintf(&ifo, "bookname=%s\n", oneLine.Replace(title))
+	fmt.Fprintf(&ifo, "wordcount=%d\n", len(words))
+	if len(syns) > 0 {
+		fmt.Fprintf(&ifo, "synwordcount=%d\n", len(syns))
+	}
+	fmt.Fprintf(&ifo, "idxfilesize=%d\n", idx.Len())
+	fmt.Fprintf(&ifo, "sametypesequence=%s\n", typ)
+	if info.Description != "" {
+		fmt.Fprintf(&ifo, "description=%s\n", oneLine.Replace(info.Description))
+	}
+	if info.CreationDate != "" {
+		fmt.Fprintf(&ifo, "date=%s\n", info.CreationDate)
+	}
+	return os.WriteFile(base+".ifo", []byte(ifo.String()), 0o644)
+}
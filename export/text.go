@@ -0,0 +1,66 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+// WriteJSON writes the entries as a JSON object from keys to definitions, which can be loaded by ondict.
+// The redirects are kept as "@@@LINK=<target>", and the definitions of a duplicated key are written as an array,
+// so a redirect never hides the other definitions.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	defs := make(map[string][]string, len(entries))
+	for _, e := range entries {
+		def := e.Definition
+		if e.Link != "" {
+			def = decoder.LinkPrefix + e.Link
+		}
+		defs[e.Key] = append(defs[e.Key], def)
+	}
+	m := make(map[string]interface{}, len(defs))
+	for k, d := range defs {
+		if len(d) == 1 {
+			m[k] = d[0]
+		} else {
+			m[k] = d
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(m)
+}
+
+// WriteJSONL writes an Entry per line.
+func WriteJSONL(w io.Writer, entries []Entry) error {
+	b := bufio.NewWriter(w)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return b.Flush()
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// WriteTSV writes a "key<TAB>definition" line per entry, the redirects are kept as "@@@LINK=<target>".
+// Backslashes, tabs and new lines are escaped as "\\", "\t", "\n" and "\r".
+func WriteTSV(w io.Writer, entries []Entry) error {
+	b := bufio.NewWriter(w)
+	for _, e := range entries {
+		def := e.Definition
+		if e.Link != "" {
+			def = decoder.LinkPrefix + e.Link
+		}
+		if _, err := b.WriteString(tsvEscaper.Replace(e.Key) + "\t" + tsvEscaper.Replace(def) + "\n"); err != nil {
+			return err
+		}
+	}
+	return b.Flush()
+}
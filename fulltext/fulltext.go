@@ -0,0 +1,230 @@
+// Package fulltext is an inverted index of the words in the definitions, for the full-text search.
+//
+// Only the documents containing all the words of a query are found by the index,
+// they should be verified by Find to match the phrase, so the positions of the words are not kept.
+package fulltext
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Index is an inverted index of the documents, which are identified by their numbers.
+type Index struct {
+	// Signature identifies the indexed documents, the index is stale if it's changed.
+	Signature string
+	// Done is the number of the indexed documents, they are indexed in order.
+	Done int
+	// Postings are the sorted numbers of the documents containing each word.
+	Postings map[string][]uint32
+}
+
+// New returns an empty index of the documents identified by signature.
+func New(signature string) *Index {
+	return &Index{Signature: signature, Postings: make(map[string][]uint32)}
+}
+
+// Add indexes the words in text of the document doc, which should be greater than the indexed ones.
+func (x *Index) Add(doc uint32, text string) {
+	seen := make(map[string]bool)
+	for _, t := range Tokens(text) {
+		if seen[t.Word] {
+			continue
+		}
+		seen[t.Word] = true
+		x.Postings[t.Word] = append(x.Postings[t.Word], doc)
+	}
+}
+
+// Candidates returns the sorted numbers of the documents containing all the words of q.
+func (x *Index) Candidates(q string) []uint32 {
+	var words []string
+	for _, t := range Tokens(q) {
+		words = append(words, t.Word)
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	// intersect from the shortest list
+	sort.Slice(words, func(i, j int) bool { return len(x.Postings[words[i]]) < len(x.Postings[words[j]]) })
+	res := x.Postings[words[0]]
+	for _, w := range words[1:] {
+		res = intersect(res, x.Postings[w])
+		if len(res) == 0 {
+			break
+		}
+	}
+	return res
+}
+
+func intersect(a, b []uint32) []uint32 {
+	var res []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// Load loads the index saved by Save in file.
+func Load(file string) (*Index, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x := &Index{}
+	if err := gob.NewDecoder(f).Decode(x); err != nil {
+		return nil, fmt.Errorf("decode index %v err: %v", file, err)
+	}
+	if x.Postings == nil {
+		x.Postings = make(map[string][]uint32)
+	}
+	return x, nil
+}
+
+// Save saves x to file, which is replaced only if it's written successfully.
+func (x *Index) Save(file string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(x); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode index %v err: %v", file, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Token is a word in a text, Start and End are its byte offsets.
+type Token struct {
+	Word       string // in lowercase
+	Start, End int
+}
+
+// Tokens splits text into the lowercase words, which consist of letters and numbers.
+func Tokens(text string) []Token {
+	var res []Token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			res = append(res, Token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		res = append(res, Token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return res
+}
+
+// Find returns the byte offsets of the first occurrence of the words of phrase in text,
+// ignoring the cases and the punctuations in between.
+func Find(text string, phrase string) (start int, end int, ok bool) {
+	words := Tokens(phrase)
+	if len(words) == 0 {
+		return 0, 0, false
+	}
+	tokens := Tokens(text)
+	for i := 0; i+len(words) <= len(tokens); i++ {
+		j := 0
+		for j < len(words) && tokens[i+j].Word == words[j].Word {
+			j++
+		}
+		if j == len(words) {
+			return tokens[i].Start, tokens[i+j-1].End, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Snippet returns the text around text[start:end], with at most n bytes on each side.
+func Snippet(text string, start int, end int, n int) string {
+	from, to := start-n, end+n
+	if from <= 0 {
+		from = 0
+	} else {
+		for from < start && !utf8.RuneStart(text[from]) {
+			from++
+		}
+	}
+	if to >= len(text) {
+		to = len(text)
+	} else {
+		for to > end && !utf8.RuneStart(text[to]) {
+			to--
+		}
+	}
+	res := strings.TrimSpace(text[from:to])
+	if from > 0 {
+		res = "..." + res
+	}
+	if to < len(text) {
+		res += "..."
+	}
+	return res
+}
+
+// blocks are the elements separating the words.
+var blocks = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "li": true, "ol": true,
+	"p": true, "section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// Text returns the text in the HTML s, without the tags, scripts and styles, the blocks are separated by spaces.
+func Text(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := "" // the script or style being skipped
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.TextToken:
+			if skip == "" {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if tag := string(name); tag == "script" || tag == "style" {
+				skip = tag
+			} else if blocks[tag] {
+				b.WriteByte(' ')
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == skip {
+				skip = ""
+			} else if blocks[string(name)] {
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package fulltext
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex(t *testing.T) {
+	x := New("sig")
+	x.Add(0, "a blood vessel, an artery")
+	x.Add(1, "The vessel of a ship")
+	x.Add(2, "blood: the red liquid, blood")
+	assert.Equal(t, []uint32{0, 2}, x.Postings["blood"])
+	assert.Equal(t, []uint32{0, 1}, x.Candidates("Vessel"))
+	assert.Equal(t, []uint32{0}, x.Candidates("blood vessel"))
+	assert.Nil(t, x.Candidates("blood ship"))
+	assert.Nil(t, x.Candidates("..."))
+
+	file := filepath.Join(t.TempDir(), "a.fulltext")
+	x.Done = 3
+	assert.Nil(t, x.Save(file))
+	y, err := Load(file)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+	_, err = Load(filepath.Join(t.TempDir(), "none"))
+	assert.NotNil(t, err)
+}
+
+func TestFind(t *testing.T) {
+	text := "a tube in the body: BLOOD-vessel, which carries blood"
+	start, end, ok := Find(text, "blood vessel")
+	assert.True(t, ok)
+	assert.Equal(t, "BLOOD-vessel", text[start:end])
+	_, _, ok = Find(text, "vessel blood")
+	assert.False(t, ok)
+	_, _, ok = Find(text, "")
+	assert.False(t, ok)
+
+	assert.Equal(t, "...the body: BLOOD-vessel, which ca...", Snippet(text, start, end, 10))
+	assert.Equal(t, text, Snippet(text, start, end, 100))
+	assert.Equal(t, "...b", Snippet("aé b", 4, 5, 2))
+}
+
+func TestText(t *testing.T) {
+	s := `<style>.a{color:red}</style><div class="a">blood<b>vessel</b></div><div>a <i>tube</i></div><script>var x = 1;</script>`
+	assert.Equal(t, "bloodvessel a tube", Text(s))
+	assert.Equal(t, "line one line two", Text("line one<br>line two"))
+	assert.Equal(t, "Tom & Jerry", Text("Tom &amp; Jerry"))
+}
@@ -3,17 +3,21 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"text/tabwriter"
 	"time"
 
 	"github.com/fatih/color"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/ChaosNyaruko/ondict/decoder"
 	"github.com/ChaosNyaruko/ondict/fzf"
 	"github.com/ChaosNyaruko/ondict/history"
 	"github.com/ChaosNyaruko/ondict/render"
@@ -48,14 +52,20 @@ var verbose = flag.Bool("v", false, "Show debug logs")
 var interactive = flag.Bool("i", false, "Launch an interactive CLI app")
 var useFzf = flag.Bool("fzf", false, "EXPERIMENTAL: whether to use fzf as the fuzzy search tool")
 var ahoFuzzy = flag.Bool("aho", false, "When enabled, searching for something will use 'aho-corasick' algorithm, which will cost much more memory, \nbut allows you to find SHORTER && SIMILAR results when you didn't type in the exact word existing in the MDX dictionaries, \ni.e. finding the LONGEST match in the MDX dictionaries. \nNOT take effect when '-fzf' is enabled.")
-var dumpMDD = flag.Bool("dump", false, "If true, it will re-dump the mdd data when launched. The dumping will be running in the background, so the server won't be stuck")
+var fuzzy = flag.Int("fuzzy", 0, "When positive, searching for something will find the keys within this edit distance, ranked by the distance and the word frequency, \ne.g. 'docter' for 'doctor', it takes precedence over '-aho'. 'fuzzy' in config.json is used if it's 0.")
+var keyIndex = flag.Bool("index", false, "If true, only the key block index of MDX/MDD files is kept in memory, and key blocks are decompressed on demand when searching, \nwhich saves a lot of memory and loading time for big dictionaries, at the cost of slightly slower lookups. \nThe dictionaries whose keys are not sorted as expected (e.g. in GBK/Big5) are fully loaded anyway.")
+var dumpMDD = flag.Bool("dump", false, "If true, the MDD files will be opened when launched, rather than on the first resource request. The loading will be running in the background, so the server won't be stuck")
 var server = flag.Bool("serve", false, "Serve as a HTTP server, default on UDS, for cache stuff, make it quicker!")
 var idleTimeout = flag.Duration("listen.timeout", defaultIdleTimeout, "Used with '-serve', the server will automatically shut down after this duration if no new requests come in")
 var listenAddr = flag.String("listen", "", "Used with '-serve', address on which to listen for remote connections. If prefixed by 'unix;', the subsequent address is assumed to be a unix domain socket. Otherwise, TCP is used.")
 var remote = flag.String("remote", "auto", "Connect to a remote address to get information, 'auto' means it will try to launch a request by UDS. If no local server is working, a new server will be created, with -listen.timeout 1 min.")
 var colour = flag.Bool("color", false, "This flags controls whether to use colors.")
-var renderFormat = flag.String("f", "", "render format, 'md' (for markdown, only for mdx engine now), or 'html'")
-var engine = flag.String("e", "", "query engine, 'mdx' or others(online query)")
+var renderFormat = flag.String("f", "", "render format, 'md' (for markdown, only for mdx engine now), 'html', \nor 'json' for the structured results of each dictionary, with the matched headword, the raw definition, the CSS and the rendered HTML and markdown")
+var engine = flag.String("e", "", "query engines, a comma-separated list of the registered sources, which are queried in order, \ne.g. 'online', 'mdx' (all the local dictionaries), 'stardict', 'dsl', 'glossary' or 'glossary,mdx'. \n'engines' in config.json is used if it's empty, 'online' if neither is set.")
+var dictNames = flag.String("dict", "", "Query only the dictionaries of these names (or aliases in config.json), separated by commas, e.g. 'oald9,ldoce', all of them are queried if it's empty")
+var search = flag.String("search", "", "Search the phrase in the definitions of the local dictionaries with the full-text indexes, which are built in the background and saved in the temporary directory, \ne.g. -search \"blood vessel\", the found headwords are shown with the text around the phrase")
+var fullText = flag.Bool("fulltext", false, "Used with '-serve', build the full-text indexes of the local dictionaries when the server starts, rather than on the first search. 'fulltext' in config.json is used if it's false.")
+var info = flag.Bool("info", false, "Show the metadata of the configured dictionaries, such as titles, entry counts and sizes")
 
 // TODO: prev work, for better source abstractions
 var g = sources.G
@@ -66,6 +76,12 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	flag.Parse()
 	if *help || flag.NFlag() == 0 || len(flag.Args()) > 0 {
 		flag.PrintDefaults()
@@ -93,6 +109,26 @@ func main() {
 		color.NoColor = true
 	}
 
+	if *keyIndex {
+		sources.KeyLookup = decoder.LookupIndex
+	}
+
+	if *fuzzy > 0 {
+		sources.FuzzyDistance = *fuzzy
+	}
+
+	if *fullText {
+		sources.FullText = true
+	}
+	// the local dictionaries may be loaded when they are looked up, e.g. with "-e glossary"
+	sources.RegisterLocal(!*ahoFuzzy, *dumpMDD)
+
+	if *info {
+		g.Load(true, false)
+		printInfo(os.Stdout, g.Info())
+		return
+	}
+
 	if *useFzf {
 		g.Load(true, false)
 		fzf.ListAllWord()
@@ -127,6 +163,9 @@ func main() {
 		}
 		log.Debugf("start a new server: %s/%s/%s/%s", network, addr, *renderFormat, *engine)
 		g.Load(!*ahoFuzzy, *dumpMDD)
+		if sources.FullText {
+			go g.BuildIndexes()
+		}
 		l, err := net.Listen(network, addr)
 		if err != nil {
 			log.Fatal("bad Listen: ", err)
@@ -164,7 +203,7 @@ func main() {
 		netConn, err = net.DialTimeout(network, address, dialTimeout)
 
 		if err == nil { // detect an exsitng server, just forward a request
-			if err := request(netConn, *engine, *renderFormat, *record); err != nil {
+			if err := request(netConn, *engine, *dictNames, *renderFormat, *record); err != nil {
 				log.Fatal(err)
 			}
 			return
@@ -182,12 +221,12 @@ func main() {
 				}
 			}
 		}
-		args := []string{
+		args := append([]string{
 			"-serve=true",
 			"-listen.timeout=2m",
 			"-e=" + *engine,
 			"-f=" + *renderFormat,
-		}
+		}, serverOptions()...)
 		log.Debugf("starting remote: %v", args)
 		if err := startRemote(dp, args...); err != nil {
 			log.Fatal(err)
@@ -202,7 +241,7 @@ func main() {
 		startDial := time.Now()
 		netConn, err = net.DialTimeout(network, address, dialTimeout)
 		if err == nil {
-			if err := request(netConn, *engine, *renderFormat, *record); err != nil {
+			if err := request(netConn, *engine, *dictNames, *renderFormat, *record); err != nil {
 				log.Fatal(err)
 			}
 			return
@@ -231,10 +270,12 @@ func main() {
 		// io.Copy(os.Stdout, fd)
 		g.Load(!*ahoFuzzy, *dumpMDD)
 	}
-	fmt.Println(query(*word, *engine, *renderFormat, *record&0x1 != 0))
+	fmt.Println(query(*word, *engine, *dictNames, *renderFormat, *record&0x1 != 0))
 }
 
-func query(word string, e string, f string, r bool) string {
+// query looks word up in the engines e, and the dictionaries d only if it's not empty, the result is rendered in format f.
+// The flags are used if they are empty.
+func query(word string, e string, d string, f string, r bool) string {
 	if r {
 		if err := history.Append(word); err != nil {
 			log.Debugf("record %v err: %v", word, err)
@@ -243,13 +284,35 @@ func query(word string, e string, f string, r bool) string {
 	if e == "" {
 		e = *engine
 	}
+	if d == "" {
+		d = *dictNames
+	}
 	if f == "" {
 		f = *renderFormat
 	}
-	if e == "mdx" {
-		return sources.QueryMDX(word, f)
+	return sources.Query(e, d, word, f)
+}
+
+func printInfo(w io.Writer, infos []sources.DictInfo) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tTITLE\tENTRIES\tCREATED\tENGINE\tSIZE\tCSS")
+	for _, d := range infos {
+		var title, created, engine, size string
+		if d.MDX != nil {
+			title, created, engine = d.MDX.Title, d.MDX.CreationDate, d.MDX.EngineVersion
+			size = fmt.Sprintf("%d", d.MDX.FileSize)
+		} else if d.StarDict != nil {
+			title, created, engine = d.StarDict.BookName, d.StarDict.Date, "StarDict "+d.StarDict.Version
+		} else if d.DSL != nil {
+			title, engine = d.DSL.Name, "DSL"
+		}
+		css := d.Css
+		if css == "" {
+			css = "(all)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", d.Name, d.Type, title, d.Entries, created, engine, size, filepath.Base(css))
 	}
-	return sources.GetFromLDOCE(word)
+	tw.Flush()
 }
 
 func Restore() {
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/sha256"
+	"flag"
 	"fmt"
 	"io"
 	"net"
@@ -11,6 +12,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/ChaosNyaruko/ondict/history"
@@ -69,7 +72,7 @@ func startRemote(dp string, args ...string) error {
 	return nil
 }
 
-func request(netConn net.Conn, e, f string, r int) error {
+func request(netConn net.Conn, e, d, f string, r int) error {
 	httpc := http.Client{
 		Transport: &http.Transport{
 			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
@@ -82,12 +85,19 @@ func request(netConn net.Conn, e, f string, r int) error {
 			log.Warnf("append %s to history err: %v", *word, err)
 		}
 	}
-	res, err := httpc.Get(fmt.Sprintf("http://fakedomain/dict?query=%s&engine=%s&format=%s&record=%d", url.QueryEscape(*word), e, f, r&0x2))
+	u := fmt.Sprintf("http://fakedomain/dict?query=%s&engine=%s&dict=%s&format=%s&record=%d", url.QueryEscape(*word), url.QueryEscape(e), url.QueryEscape(d), f, r&0x2)
+	if *search != "" {
+		u = fmt.Sprintf("http://fakedomain/search?q=%s&dict=%s&format=%s", url.QueryEscape(*search), url.QueryEscape(d), f)
+	}
+	res, err := httpc.Get(u)
 	if err != nil {
 		log.SetOutput(os.Stderr)
 		log.Fatalf("new request error %v", err)
 	}
 	defer res.Body.Close()
+	if warning := optionsWarning(res.Header.Get(optionsHeader)); warning != "" {
+		log.Warn(warning)
+	}
 	if res, err := io.ReadAll(res.Body); err != nil {
 		return fmt.Errorf("read body error %v", err)
 	} else {
@@ -97,3 +107,44 @@ func request(netConn net.Conn, e, f string, r int) error {
 
 	return nil
 }
+
+// optionsHeader is the response header of the options of the server, see serverOptions.
+const optionsHeader = "Ondict-Options"
+
+// serverOptions are the flags taking effect when the server loads the dictionaries,
+// a running server can't change them for a request.
+func serverOptions() []string {
+	return []string{
+		"-index=" + strconv.FormatBool(*keyIndex),
+		"-fuzzy=" + strconv.Itoa(*fuzzy),
+		"-fulltext=" + strconv.FormatBool(*fullText),
+	}
+}
+
+// optionsWarning returns a warning if the server options set in the command line differ from those of the running server,
+// which are in the form of optionsHeader. It's empty if they're the same, or the server doesn't tell its options.
+func optionsWarning(running string) string {
+	if running == "" {
+		return ""
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	using := make(map[string]string)
+	for _, o := range strings.Fields(running) {
+		name, value, _ := strings.Cut(strings.TrimPrefix(o, "-"), "=")
+		using[name] = value
+	}
+	var ignored []string
+	for _, o := range serverOptions() {
+		name, value, _ := strings.Cut(strings.TrimPrefix(o, "-"), "=")
+		if v, ok := using[name]; ok && set[name] && v != value {
+			ignored = append(ignored, o)
+		}
+	}
+	if len(ignored) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("the running server is started with %s, so %s is ignored, "+
+		"it takes effect after the server exits (when it's idle for -listen.timeout) and a new one is started",
+		running, strings.Join(ignored, " "))
+}
@@ -10,6 +10,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
+
+	"github.com/ChaosNyaruko/ondict/dsl"
 )
 
 type Renderer interface {
@@ -20,45 +22,89 @@ const (
 	Longman5Online = "LONGMAN5/Online"
 	LongmanEasy    = "LONGMAN/Easy"
 	OLD9           = "OLD9"
+	// DSL is for the dictionaries in the DSL markup, which is converted to HTML first, see dsl.ToHTML.
+	DSL = "DSL"
+	// Glossary is for the hand-maintained glossaries, whose definitions are markdown or plain text.
+	Glossary = "glossary"
 )
 
 type HTMLRender struct {
 	Raw        string
 	SourceType string
+	// Dict is the name of the source dictionary, the links to its resources, such as sound://, <img src>
+	// and <link href>, are rewritten to ResourceURL, so that resources of different dictionaries never collide.
+	// The links are left as they are for dictionaries of other types if it's empty.
+	Dict string
 }
 
 func (h *HTMLRender) Render() string {
-	if !strings.HasPrefix(h.SourceType, "LONGMAN") {
-		return h.Raw
+	raw := h.Raw
+	if h.SourceType == DSL {
+		raw = dsl.ToHTML(raw)
+	} else if h.SourceType == Glossary {
+		raw = markdownToHTML(raw)
+	}
+	if !strings.HasPrefix(h.SourceType, "LONGMAN") && h.Dict == "" {
+		return raw
 	}
-	info := strings.NewReader(h.Raw)
-	doc, err := html.ParseWithOptions(info, html.ParseOptionEnableScripting(false))
+	// a definition is a fragment of the page, so it's not wrapped in <html><head></head><body>
+	body := &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"}
+	nodes, err := html.ParseFragmentWithOptions(strings.NewReader(raw), body, html.ParseOptionEnableScripting(false))
 	if err != nil {
-		log.Fatal(err)
+		log.Debugf("html.Parse err: %v", err)
+		return raw
 	}
-	dfs(doc, 0, nil, "")
 	var b bytes.Buffer
-	err = html.Render(&b, doc)
-	if err != nil {
-		log.Debugf("html.Render err: %v", err)
-		return h.Raw
+	for _, n := range nodes {
+		dfs(n, 0, nil, h.Dict)
+		if err := html.Render(&b, n); err != nil {
+			log.Debugf("html.Render err: %v", err)
+			return raw
+		}
 	}
 	return b.String()
 }
 
-func modifyImgSrc(n *html.Node) {
-	if n.Type != html.ElementNode || (n.DataAtom.String() != "img" && n.Data != "img") {
-		log.Fatalf("Error: an img element is expected")
+// ResourceURL is where the server serves the resource at path in the MDD file of dict.
+// It's the flat "/path" if dict is empty, which may be in any of the dictionaries.
+func ResourceURL(dict string, path string) string {
+	path = strings.TrimLeft(strings.ReplaceAll(path, "\\", "/"), "/")
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	if dict == "" {
+		return "/" + strings.Join(segments, "/")
+	}
+	return "/res/" + url.PathEscape(dict) + "/" + strings.Join(segments, "/")
+}
+
+// isRelative reports whether the link refers to a file in the MDD file.
+func isRelative(link string) bool {
+	if link == "" || strings.HasPrefix(link, "/") || strings.HasPrefix(link, "#") {
+		return false
+	}
+	u, err := url.Parse(link)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// modifyResource rewrites the attribute key of n to ResourceURL, if it's a relative link, such as
+// <img src="img/a.png"> or <link href="a.css">, or a link with the file:// scheme.
+func modifyResource(n *html.Node, key string, dict string) {
+	if dict == "" {
+		return
 	}
 	for i, a := range n.Attr {
-		if a.Key == "src" {
-			n.Attr[i].Val = "tmp/" + a.Val
+		if a.Key != key {
+			continue
+		}
+		if path := strings.TrimPrefix(a.Val, "file://"); path != a.Val || isRelative(path) {
+			n.Attr[i].Val = ResourceURL(dict, path)
 		}
 	}
-	// log.Debugf("modifyImgSrc %#v", n)
 }
 
-func replaceMp3(n *html.Node, val string) {
+func replaceMp3(n *html.Node, val string, dict string) {
 	if false {
 		var b bytes.Buffer
 		err := html.Render(&b, n)
@@ -73,8 +119,11 @@ func replaceMp3(n *html.Node, val string) {
 		file.Close()
 	}
 	name := strings.TrimSuffix(url.QueryEscape(strings.TrimPrefix(val, "sound://")), ".mp3")
-	new := fmt.Sprintf("/%s", url.QueryEscape(strings.TrimPrefix(val, "sound://")))
-	log.Infof("href sound: %v, new: %q", strings.TrimPrefix(val, "sound://"), new)
+	new := ResourceURL(dict, strings.TrimPrefix(val, "sound://"))
+	if dict == "" {
+		new = fmt.Sprintf("/%s", url.QueryEscape(strings.TrimPrefix(val, "sound://")))
+	}
+	log.Debugf("href sound: %v, new: %q", strings.TrimPrefix(val, "sound://"), new)
 	n.DataAtom = atom.Div
 	n.Data = "div"
 	n.Attr = []html.Attribute{
@@ -144,38 +193,46 @@ func newAudioTag(src string) *html.Node {
 	return &res
 }
 
-func modifyHref(n *html.Node) {
+func modifyHref(n *html.Node, dict string) {
 	for i, a := range n.Attr {
 		if a.Key == "href" {
-			if strings.HasPrefix(a.Val, "entry://") {
+			if strings.HasPrefix(a.Val, "entry://#") { // an anchor in the same entry
+				n.Attr[i].Val = strings.TrimPrefix(a.Val, "entry://")
+			} else if strings.HasPrefix(a.Val, "entry://") {
 				new := fmt.Sprintf("/dict?query=%s&engine=mdx&format=html", url.QueryEscape(strings.TrimPrefix(a.Val, "entry://")))
-				log.Infof("href entry: %v, new: %q", strings.TrimPrefix(a.Val, "entry://"), new)
+				if dict != "" {
+					new += "&dict=" + url.QueryEscape(dict)
+				}
+				log.Debugf("href entry: %v, new: %q", strings.TrimPrefix(a.Val, "entry://"), new)
 				n.Attr[i].Val = new
 			} else if strings.HasPrefix(a.Val, "sound://") {
-				replaceMp3(n, a.Val)
+				replaceMp3(n, a.Val, dict)
 			}
 		}
 	}
 }
 
-func dfs(n *html.Node, level int, parent *html.Node, ft string) string {
+// dfs rewrites the links in n, dict is the name of the source dictionary, see HTMLRender.
+func dfs(n *html.Node, level int, parent *html.Node, dict string) string {
 	if n.Type == html.TextNode {
-		log.Infof("TextNode: %v, DataAtom:%v", n.Type, n.DataAtom)
+		log.Debugf("TextNode: %v, DataAtom:%v", n.Type, n.DataAtom)
 		return ""
 	}
 	if IsElement(n, "a", "") {
 		log.Debugf("<a> %v", n)
-		modifyHref(n)
+		// the children are kept even if it's replaced by a div for sound://, e.g. <img> of the speaker icons
+		modifyHref(n, dict)
+	} else if IsElement(n, "img", "") {
+		modifyResource(n, "src", dict)
 		return ""
-	}
-	if IsElement(n, "img", "") {
-		// modifyImgSrc(n)
+	} else if IsElement(n, "link", "") {
+		modifyResource(n, "href", dict)
 		return ""
 	}
 
 	var s string
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		s += dfs(c, level+1, n, ft)
+		s += dfs(c, level+1, n, dict)
 	}
 	return s
 }
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResourceURL(t *testing.T) {
+	assert.Equal(t, "/res/ldoce/US_doctor1.mp3", ResourceURL("ldoce", "US_doctor1.mp3"))
+	assert.Equal(t, "/res/LDOCE5++%20V%201-35/img/a%20b.png", ResourceURL("LDOCE5++ V 1-35", `\img\a b.png`))
+	assert.Equal(t, "/img/a.png", ResourceURL("", "/img/a.png"))
+}
+
+func Test_RenderResourceLinks(t *testing.T) {
+	raw := `<link rel="stylesheet" href="oald9.css"><div>` +
+		`<a href="sound://US_doctor1.mp3">play</a>` +
+		`<a href="entry://doctor">doctor</a>` +
+		`<a href="entry://#top">top</a>` +
+		`<img src="img/doctor.png"><img src="file://img/x.png"><img src="https://example.com/a.png"><img src="data:image/png;base64,AAAA">` +
+		`</div>`
+	h := HTMLRender{Raw: raw, SourceType: "OALD9", Dict: "oald9"}
+	out := h.Render()
+	assert.Contains(t, out, `href="/res/oald9/oald9.css"`)
+	assert.Contains(t, out, `src="/res/oald9/US_doctor1.mp3"`)
+	assert.Contains(t, out, `href="/dict?query=doctor&amp;engine=mdx&amp;format=html&amp;dict=oald9"`)
+	assert.Contains(t, out, `href="#top"`)
+	assert.Contains(t, out, `src="/res/oald9/img/doctor.png"`)
+	assert.Contains(t, out, `src="/res/oald9/img/x.png"`)
+	assert.Contains(t, out, `src="https://example.com/a.png"`)
+	assert.Contains(t, out, `src="data:image/png;base64,AAAA"`)
+
+	// other dictionaries are not affected
+	h = HTMLRender{Raw: raw, SourceType: "OALD9", Dict: "ldoce"}
+	assert.Contains(t, h.Render(), `src="/res/ldoce/US_doctor1.mp3"`)
+	// without the identity, non-LONGMAN dictionaries are kept as they are
+	h = HTMLRender{Raw: raw, SourceType: "OALD9"}
+	assert.Equal(t, raw, h.Render())
+}
+
+func Test_RenderLinkedImages(t *testing.T) {
+	raw := `<a href="sound://a.mp3"><img src="img/spk.png"></a><a href="entry://apple"><img src="img/apple.png"></a>`
+	h := HTMLRender{Raw: raw, SourceType: "OALD9", Dict: "oald9"}
+	out := h.Render()
+	assert.Contains(t, out, `src="/res/oald9/img/spk.png"`)
+	assert.Contains(t, out, `src="/res/oald9/a.mp3"`)
+	assert.Contains(t, out, `<a href="/dict?query=apple&amp;engine=mdx&amp;format=html&amp;dict=oald9"><img src="/res/oald9/img/apple.png"/></a>`)
+	assert.NotContains(t, out, "<html>")
+	assert.NotContains(t, out, "<body>")
+
+	h = HTMLRender{Raw: `<p>a</p><p>b</p>`, SourceType: "OALD9", Dict: "oald9"}
+	assert.Equal(t, `<p>a</p><p>b</p>`, h.Render())
+}
@@ -0,0 +1,80 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	mdList   = regexp.MustCompile(`^\s*(?:[-*+]|\d+\.)\s+`)
+	mdHeader = regexp.MustCompile(`^(#{1,6})\s+`)
+)
+
+// markdownToHTML converts the lightweight markdown of glossaries to HTML, plain text is kept as it is,
+// except that the line breaks are kept. Only the paragraphs, headers, lists, code spans, links,
+// bold and italic text are supported.
+func markdownToHTML(s string) string {
+	var b strings.Builder
+	var paragraph []string
+	inList := false
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + strings.Join(paragraph, "<br>") + "</p>\n")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if inList {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			closeList()
+		case mdHeader.MatchString(line):
+			flush()
+			closeList()
+			level := len(mdHeader.FindStringSubmatch(line)[1])
+			tag := []string{"", "h1", "h2", "h3", "h4", "h5", "h6"}[level]
+			b.WriteString("<" + tag + ">" + inlineMarkdown(mdHeader.ReplaceAllString(line, "")) + "</" + tag + ">\n")
+		case mdList.MatchString(line):
+			flush()
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			b.WriteString("<li>" + inlineMarkdown(mdList.ReplaceAllString(line, "")) + "</li>\n")
+		default:
+			closeList()
+			paragraph = append(paragraph, inlineMarkdown(strings.TrimSpace(line)))
+		}
+	}
+	flush()
+	closeList()
+	return b.String()
+}
+
+func inlineMarkdown(s string) string {
+	s = html.EscapeString(s)
+	// keep the code spans away from the other rules
+	var codes []string
+	s = mdCode.ReplaceAllStringFunc(s, func(c string) string {
+		codes = append(codes, "<code>"+c[1:len(c)-1]+"</code>")
+		return "\x00"
+	})
+	s = mdLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = mdBold.ReplaceAllString(s, "<b>$1$2</b>")
+	s = mdItalic.ReplaceAllString(s, "<i>$1$2</i>")
+	for _, c := range codes {
+		s = strings.Replace(s, "\x00", c, 1)
+	}
+	return s
+}
@@ -0,0 +1,20 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_markdownToHTML(t *testing.T) {
+	for md, want := range map[string]string{
+		"plain text":              "<p>plain text</p>\n",
+		"line 1\nline 2\n\nnext":  "<p>line 1<br>line 2</p>\n<p>next</p>\n",
+		"**SLO** is *not* an SLA": "<p><b>SLO</b> is <i>not</i> an SLA</p>\n",
+		"see [docs](https://example.com/a_b) or `a*b*c`": `<p>see <a href="https://example.com/a_b">docs</a> or <code>a*b*c</code></p>` + "\n",
+		"# Title\n- one\n- two <b>\nafter":               "<h1>Title</h1>\n<ul>\n<li>one</li>\n<li>two &lt;b&gt;</li>\n</ul>\n<p>after</p>\n",
+		"snake_case_name":                                "<p>snake_case_name</p>\n",
+	} {
+		assert.Equal(t, want, markdownToHTML(md), md)
+	}
+}
@@ -3,9 +3,16 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/ChaosNyaruko/ondict/sources"
 )
 
 // dbName is the name used in the repl prompts
@@ -31,6 +38,8 @@ func displayHelp() {
 	fmt.Println(".help    - Show available commands")
 	fmt.Println(".store   - Store the history to a JSON file")
 	fmt.Println(".restore - Restore the history from a JSON file")
+	fmt.Println(".use     - Show the dictionaries, '.use a,b' queries only the dictionaries a and b, '.use *' queries all")
+	fmt.Println(".search  - '.search phrase' finds the entries whose definitions contain the phrase")
 	fmt.Println(".clear   - Clear the terminal screen")
 	fmt.Println(".exit    - Closes your connection to", cliName)
 }
@@ -49,7 +58,64 @@ func handleInvalidCmd(text string) {
 
 // handleCmd parses the given commands
 func handleCmd(text string) {
-	handleInvalidCmd(text)
+	cmd, args, _ := strings.Cut(text, " ")
+	switch cmd {
+	case ".use":
+		useDicts(os.Stdout, strings.TrimSpace(args))
+	case ".search":
+		if phrase := strings.TrimSpace(args); phrase != "" {
+			hits, building := g.Select(sources.DictNames(*dictNames)).Search(phrase, 10)
+			writeHits(os.Stdout, phrase, hits, building)
+		} else {
+			handleInvalidCmd(text)
+		}
+	default:
+		handleInvalidCmd(text)
+	}
+}
+
+// useDicts switches the active dictionaries to names, or shows the dictionaries if names is empty.
+func useDicts(w io.Writer, names string) {
+	switch names {
+	case "":
+	case "*":
+		*dictNames = ""
+	default:
+		if len(*g.Select(sources.DictNames(names))) == 0 {
+			fmt.Fprintf(w, "no dictionary named %q\n", names)
+			return
+		}
+		*dictNames = names
+	}
+	active := g.Select(sources.DictNames(*dictNames))
+	for _, d := range *g {
+		mark := " "
+		for _, a := range *active {
+			if a == d {
+				mark = "*"
+			}
+		}
+		name := d.Name()
+		if d.Alias != "" {
+			name += " (" + d.Alias + ")"
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, name)
+	}
+}
+
+// pickList shows the numbered suggestions for word if it's not found in the dictionaries, and returns them.
+func pickList(w io.Writer, word string) []string {
+	suggestions := g.Select(sources.DictNames(*dictNames)).Suggest(word, 9)
+	if len(suggestions) == 0 || suggestions[0].Matched() {
+		return nil
+	}
+	fmt.Fprintln(w, "Did you mean (input the number to query it):")
+	var res []string
+	for i, s := range suggestions {
+		fmt.Fprintf(w, "%d) %s [%s]\n", i+1, s.Word, s.Kind)
+		res = append(res, s.Word)
+	}
+	return res
 }
 
 // cleanInput preprocesses input to the db repl
@@ -68,11 +134,16 @@ func startLoop() {
 		".restore": Restore,
 	}
 	// Begin the repl loop
-	reader := bufio.NewScanner(os.Stdin)
+	reader := newLineReader()
+	var picks []string // the suggestions of the last query, which can be picked by their numbers
 	displayHelp()
-	printPrompt()
-	for reader.Scan() {
-		text := cleanInput(reader.Text())
+	var err error
+	for {
+		var line string
+		if line, err = reader.ReadLine(); err != nil {
+			break
+		}
+		text := cleanInput(line)
 		if command, exists := commands[text]; exists {
 			// Call a hardcoded function
 			command.(func())()
@@ -83,10 +154,101 @@ func startLoop() {
 			// Pass the command to the parser
 			handleCmd(text)
 		} else {
-			fmt.Println(query(text, *engine, *renderFormat, true))
+			if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= len(picks) {
+				text = picks[n-1]
+			}
+			fmt.Println(query(text, *engine, *dictNames, *renderFormat, true))
+			picks = pickList(os.Stdout, text)
 		}
-		printPrompt()
+	}
+	if err == io.EOF {
+		err = nil
 	}
 	// Print an additional line if we encountered an EOF character
-	fmt.Printf("loop ends: %v\n", reader.Err())
+	fmt.Printf("loop ends: %v\n", err)
+}
+
+// lineReader reads the input lines of the repl, with the prompts.
+type lineReader interface {
+	ReadLine() (string, error)
+}
+
+func newLineReader() lineReader {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return scanReader{bufio.NewScanner(os.Stdin)}
+	}
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, cliName+"> ")
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return completeLine(t, line, pos)
+	}
+	return termReader{fd, t}
+}
+
+type scanReader struct {
+	*bufio.Scanner
+}
+
+func (s scanReader) ReadLine() (string, error) {
+	printPrompt()
+	if s.Scan() {
+		return s.Text(), nil
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+// termReader reads the lines from a terminal, with the line editing, the history and the tab completion.
+// The terminal is in raw mode only when a line is being read, so the outputs are printed as usual.
+type termReader struct {
+	fd int
+	t  *term.Terminal
+}
+
+func (r termReader) ReadLine() (string, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(r.fd, state)
+	return r.t.ReadLine()
+}
+
+// completeLine completes the word before pos in line with the keys of the active dictionaries.
+// The longest common prefix of the keys is completed, or the keys are listed in w if there is none.
+func completeLine(w io.Writer, line string, pos int) (string, int, bool) {
+	prefix := line[:pos]
+	if strings.TrimSpace(prefix) == "" || strings.HasPrefix(prefix, ".") {
+		return "", 0, false
+	}
+	keys := g.Select(sources.DictNames(*dictNames)).Complete(prefix, 20)
+	if len(keys) == 0 {
+		return "", 0, false
+	}
+	if len(keys) == 1 {
+		return keys[0] + line[pos:], len(keys[0]), true
+	}
+	common := []rune(strings.ToLower(keys[0]))
+	for _, k := range keys[1:] {
+		r := []rune(strings.ToLower(k))
+		n := 0
+		for n < len(common) && n < len(r) && common[n] == r[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if typed := utf8.RuneCountInString(prefix); len(common) > typed {
+		completed := prefix + string(common[typed:])
+		return completed + line[pos:], len(completed), true
+	}
+	fmt.Fprintln(w, strings.Join(keys, "  "))
+	return "", 0, false
 }
@@ -1,16 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
 	"html/template"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/ChaosNyaruko/ondict/util"
+	"github.com/ChaosNyaruko/ondict/decoder"
+	"github.com/ChaosNyaruko/ondict/sources"
 )
 
+// legacyResources are the extensions of the resources which may be linked by the flat URLs "/<path>",
+// rather than "/res/<dict>/<path>", e.g. the sounds and images of LONGMAN dictionaries, see render.ResourceURL.
+var legacyResources = map[string]bool{
+	".mp3": true, ".wav": true, ".ogg": true, ".spx": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".bmp": true,
+}
+
 type proxy struct {
 	timeout *time.Timer
 }
@@ -27,6 +46,7 @@ func (s *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.timeout.Reset(*idleTimeout)
 	}
 	log.Debugf("query HTTP path: %v", r.URL.Path)
+	w.Header().Set(optionsHeader, strings.Join(serverOptions(), " "))
 	if r.URL.Path == "/" {
 		tmplt := template.New("portal")
 		tmplt, err := tmplt.Parse(portal)
@@ -39,30 +59,220 @@ func (s *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if strings.HasPrefix(r.URL.Path, "/res/") {
+		name, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/res/"), "/")
+		serveResource(w, r, name, path)
+		return
+	}
 	if strings.HasSuffix(r.URL.Path, "/dict") {
 		q := r.URL.Query()
 		word := q.Get("query")
 		e := q.Get("engine")
+		d := q.Get("dict")
 		f := q.Get("format")
 		r := q.Get("record")
-		log.Debugf("query dict: %v, engine: %v, format: %v", word, e, f)
+		log.Debugf("query dict: %v, engine: %v, dicts: %v, format: %v", word, e, d, f)
 
-		res := query(word, e, f, r != "0")
+		res := query(word, e, d, f, r != "0")
+		if f == "json" || f == "" && *renderFormat == "json" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
 		w.WriteHeader(200)
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Write([]byte(res))
 		w.(http.Flusher).Flush()
 		// w.Write([]byte("<style>" + odecss + "</style>"))
 		// w.Write([]byte(fmt.Sprintf(`<link ref="stylesheet" type="text/css", href=/d/static/oald9.css />`)))
 		return
 	}
+	if strings.HasSuffix(r.URL.Path, "/autocomplete") {
+		serveAutocomplete(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/suggest") {
+		serveSuggest(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/search") {
+		serveSearch(w, r)
+		return
+	}
+	if r.URL.Path == "/info" {
+		res, err := json.Marshal(g.Info())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res)
+		return
+	}
+	// sounds and images of the old flat URLs, which may be in any of the dictionaries
+	if legacyResources[strings.ToLower(filepath.Ext(r.URL.Path))] {
+		if data, err := g.Resource("", r.URL.Path); err == nil {
+			serveContent(w, r, r.URL.Path, data)
+			return
+		}
+	}
 	// if strings.HasSuffix(r.URL.Path, ".css") {
 	// 	log.Debugf("static info: %v", r.URL.Path)
 	// 	http.FileServer(http.Dir("./static")).ServeHTTP(w, r)
 	// 	return
 	// }
-	log.Infof("URL: %v, Scheme: %v", r.URL, r.URL.Scheme)
-	http.FileServer(http.Dir(util.TmpDir())).ServeHTTP(w, r)
+	// the temporary directory isn't served, which has the stale dumped resources and the full-text indexes
+	log.Debugf("not found URL: %v", r.URL)
+	http.NotFound(w, r)
+}
+
+// completion is a result of /autocomplete, in the form of the AutoCompleter in util.CommonJS.
+type completion struct {
+	SearchText string `json:"searchtext"`
+}
+
+// serveAutocomplete serves the keys starting with "q" in JSON, at most "limit" (10 by default) of them,
+// in the dictionaries of "dict" if it's set, see sources.Dicts.Complete.
+func serveAutocomplete(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := struct {
+		Results []completion `json:"results"`
+	}{Results: []completion{}}
+	if strings.TrimSpace(q.Get("q")) != "" {
+		for _, k := range g.Select(sources.DictNames(q.Get("dict"))).Complete(q.Get("q"), limit) {
+			res.Results = append(res.Results, completion{k})
+		}
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// parseLimit parses the "limit" parameter, which is 10 if it's empty.
+func parseLimit(l string) (int, error) {
+	if l == "" {
+		return 10, nil
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad limit %q", l)
+	}
+	return n, nil
+}
+
+// serveSuggest serves the suggestions for "q" in JSON, at most "limit" (10 by default) of them,
+// in the dictionaries of "dict" if it's set, see sources.Dicts.Suggest.
+func serveSuggest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := json.Marshal(g.Select(sources.DictNames(q.Get("dict"))).Suggest(q.Get("q"), limit))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// serveSearch serves the entries whose definitions contain the phrase "q", at most "limit" (10 by default) of them,
+// in the dictionaries of "dict" if it's set, see sources.Dicts.Search.
+// They are in JSON if "format" is "json", a list of links if it's "html", or plain text lines otherwise.
+func serveSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hits, building := []sources.Hit{}, []sources.IndexProgress{}
+	if strings.TrimSpace(q.Get("q")) != "" {
+		hits, building = g.Select(sources.DictNames(q.Get("dict"))).Search(q.Get("q"), limit)
+	}
+	f := q.Get("format")
+	if f == "" {
+		f = *renderFormat
+	}
+	var b strings.Builder
+	switch f {
+	case "json":
+		res, err := json.Marshal(struct {
+			Results  []sources.Hit           `json:"results"`
+			Indexing []sources.IndexProgress `json:"indexing,omitempty"`
+		}{hits, building})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res)
+		return
+	case "html":
+		for _, p := range building {
+			fmt.Fprintf(&b, "<p class=\"ondict-indexing\">%s</p>\n", html.EscapeString(p.String()))
+		}
+		b.WriteString("<ul class=\"ondict-search\">\n")
+		for _, h := range hits {
+			fmt.Fprintf(&b, "<li><a href=\"/dict?query=%s&engine=mdx&format=html\">%s</a> <i>%s</i>: %s</li>\n",
+				url.QueryEscape(h.Word), html.EscapeString(h.Word), html.EscapeString(h.Dict), html.EscapeString(h.Snippet))
+		}
+		b.WriteString("</ul>\n")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	default:
+		writeHits(&b, q.Get("q"), hits, building)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Write([]byte(b.String()))
+}
+
+// writeHits writes the hits of the full-text search for phrase in plain text lines,
+// after the notes of the indexes being built.
+func writeHits(w io.Writer, phrase string, hits []sources.Hit, building []sources.IndexProgress) {
+	for _, p := range building {
+		fmt.Fprintln(w, p)
+	}
+	for _, h := range hits {
+		fmt.Fprintf(w, "%s (%s): %s\n", h.Word, h.Dict, h.Snippet)
+	}
+	if len(hits) == 0 {
+		fmt.Fprintf(w, "%q is not found.\n", phrase)
+	}
+}
+
+// serveResource serves the resource at path in the MDD file of the dictionary named name.
+func serveResource(w http.ResponseWriter, r *http.Request, name string, path string) {
+	data, err := g.Resource(name, path)
+	if errors.Is(err, decoder.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveContent(w, r, path, data)
+}
+
+// serveContent serves data with an ETag, the Content-Type is decided by the extension of path, or sniffed.
+func serveContent(w http.ResponseWriter, r *http.Request, path string, data []byte) {
+	h := fnv.New64a()
+	h.Write(data)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, h.Sum64()))
+	if ct := mime.TypeByExtension(filepath.Ext(path)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(data))
 }
 
 func ParseAddr(listen string) (network string, address string) {
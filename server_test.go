@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+	"github.com/ChaosNyaruko/ondict/sources"
+	"github.com/ChaosNyaruko/ondict/util"
+)
+
+func Test_serveResource(t *testing.T) {
+	dir, res := t.TempDir(), t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(res, "img"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(res, "img", "a.png"), []byte("\x89PNG a"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(res, "a.css"), []byte("p {}"), 0o644))
+	e := decoder.Encoder{}
+	var mdx, mdd bytes.Buffer
+	assert.Nil(t, e.WriteMDX(&mdx, map[string]string{"a": "<img src=\"img/a.png\">"}))
+	assert.Nil(t, e.WriteMDD(&mdd, res))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "test dict.mdx"), mdx.Bytes(), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "test dict.mdd"), mdd.Bytes(), 0o644))
+	d := &sources.MdxDict{MdxFile: filepath.Join(dir, "test dict")}
+	assert.Nil(t, d.Register(true, false))
+	old := *g
+	*g = sources.Dicts{d}
+	defer func() { *g = old }()
+
+	w := httptest.NewRecorder()
+	serveResource(w, httptest.NewRequest("GET", "/res/test%20dict/IMG/A.png", nil), "test dict", "IMG/A.png")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "\x89PNG a", w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	r := httptest.NewRequest("GET", "/res/test%20dict/img/a.png", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	serveResource(w, r, "test dict", "img/a.png")
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	serveResource(w, httptest.NewRequest("GET", "/res/test%20dict/img/b.png", nil), "test dict", "img/b.png")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// only the sounds and images are served by the flat URLs
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	p := &proxy{timeout: time.NewTimer(time.Hour)}
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/img/a.png", nil))
+	assert.Equal(t, "\x89PNG a", w.Body.String())
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/a.css", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/res/test%20dict/a.css", nil))
+	assert.Equal(t, "p {}", w.Body.String())
+	// nor the files in the temporary directory
+	assert.Nil(t, os.WriteFile(filepath.Join(util.TmpDir(), "a.fulltext"), []byte("index"), 0o644))
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/a.fulltext", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/info", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/moreinfo", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_serveSuggest(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "team")
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("doctor\ta physician\ndocker\ta container tool\n"), 0o644))
+	d := &sources.MdxDict{MdxFile: base, Type: "glossary"}
+	assert.Nil(t, d.Register(true, false))
+	old := *g
+	*g = sources.Dicts{d}
+	defer func() { *g = old }()
+
+	var w *httptest.ResponseRecorder
+	var res []sources.Suggestion
+	// the fuzzy suggestions are ready when the BKTree is built in the background
+	assert.Eventually(t, func() bool {
+		w = httptest.NewRecorder()
+		serveSuggest(w, httptest.NewRequest("GET", "/suggest?q=docter&limit=1", nil))
+		res = nil
+		return json.Unmarshal(w.Body.Bytes(), &res) == nil && len(res) > 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, []sources.Suggestion{{Word: "docker", Kind: sources.SuggestFuzzy, Distance: 1, Dicts: []string{"team"}}}, res)
+
+	w = httptest.NewRecorder()
+	serveSuggest(w, httptest.NewRequest("GET", "/suggest?q=docter&dict=nothing", nil))
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = httptest.NewRecorder()
+	serveSuggest(w, httptest.NewRequest("GET", "/suggest?q=docter&limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var out bytes.Buffer
+	assert.Equal(t, []string{"docker", "doctor"}, pickList(&out, "docter"))
+	assert.Equal(t, "Did you mean (input the number to query it):\n1) docker [fuzzy]\n2) doctor [fuzzy]\n", out.String())
+	assert.Nil(t, pickList(&out, "doctor"))
+}
+
+func Test_serveAutocomplete(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "team")
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("doctor\ta physician\ndocker\ta container tool\nDocument\ta paper\n"), 0o644))
+	d := &sources.MdxDict{MdxFile: base, Type: "glossary"}
+	assert.Nil(t, d.Register(true, false))
+	old := *g
+	*g = sources.Dicts{d}
+	defer func() { *g = old }()
+
+	w := httptest.NewRecorder()
+	serveAutocomplete(w, httptest.NewRequest("GET", "/autocomplete?q=DOC&limit=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"results": [{"searchtext": "docker"}, {"searchtext": "doctor"}]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	serveAutocomplete(w, httptest.NewRequest("GET", "/autocomplete?q=", nil))
+	assert.JSONEq(t, `{"results": []}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	serveAutocomplete(w, httptest.NewRequest("GET", "/autocomplete?q=doc&limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var out bytes.Buffer
+	line, pos, ok := completeLine(&out, "Docu", 4)
+	assert.True(t, ok)
+	assert.Equal(t, "Document", line)
+	assert.Equal(t, 8, pos)
+	line, pos, ok = completeLine(&out, "d", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "doc", line)
+	assert.Equal(t, 3, pos)
+	_, _, ok = completeLine(&out, "doc", 3)
+	assert.False(t, ok)
+	assert.Equal(t, "docker  doctor  Document\n", out.String())
+	_, _, ok = completeLine(&out, ".us", 3)
+	assert.False(t, ok)
+}
+
+func Test_serveSearch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	base := filepath.Join(t.TempDir(), "team")
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("doctor\ta person who treats the <b>sick</b> people\nnurse\ta person who cares for sick people\n"), 0o644))
+	d := &sources.MdxDict{MdxFile: base, Type: "glossary"}
+	assert.Nil(t, d.Register(true, false))
+	old := *g
+	*g = sources.Dicts{d}
+	defer func() { *g = old }()
+	g.BuildIndexes()
+	assert.Eventually(t, func() bool { return d.Progress().Indexed == 2 }, time.Second, 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	serveSearch(w, httptest.NewRequest("GET", "/search?q=sick+people&format=json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"results": [
+		{"dict": "team", "word": "doctor", "snippet": "a person who treats the sick people"},
+		{"dict": "team", "word": "nurse", "snippet": "a person who cares for sick people"}
+	]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	serveSearch(w, httptest.NewRequest("GET", "/search?q=treats&format=text", nil))
+	assert.Equal(t, "doctor (team): a person who treats the sick people\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	serveSearch(w, httptest.NewRequest("GET", "/search?q=cares&format=html", nil))
+	assert.Contains(t, w.Body.String(), `<a href="/dict?query=nurse&engine=mdx&format=html">nurse</a>`)
+
+	w = httptest.NewRecorder()
+	serveSearch(w, httptest.NewRequest("GET", "/search?q=people+sick", nil))
+	assert.Equal(t, "\"people sick\" is not found.\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	serveSearch(w, httptest.NewRequest("GET", "/search?q=sick&limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_optionsWarning(t *testing.T) {
+	assert.Equal(t, "", optionsWarning(""))
+	assert.Equal(t, "", optionsWarning("-index=true -fuzzy=2 -fulltext=true"), "the options not set are not compared")
+
+	old := *fuzzy
+	defer func() { *fuzzy = old }()
+	assert.Nil(t, flag.Set("fuzzy", "2"))
+	assert.Equal(t, "", optionsWarning("-index=false -fuzzy=2 -fulltext=false"))
+	assert.Contains(t, optionsWarning("-index=false -fuzzy=0 -fulltext=false"), "so -fuzzy=2 is ignored")
+
+	w := httptest.NewRecorder()
+	p := &proxy{timeout: time.NewTimer(time.Hour)}
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/info", nil))
+	assert.Equal(t, "-index=false -fuzzy=2 -fulltext=false", w.Header().Get(optionsHeader))
+}
@@ -53,7 +53,9 @@ func (ack *AhoCorasick) GetRawOutputs(input string) []RawOutput {
 	for i, match := range matches {
 		log.Debugf("%d th match: pos[%v], pattern[%v], string[%v]\n", i, match.Pos(), match.Pattern(), match.MatchString())
 		for _, v := range ack.lowDict[match.MatchString()] {
-			res = append(res, output{v, ack.dict.Get(v)})
+			for _, def := range getAll(ack.dict, v) {
+				res = append(res, output{v, def})
+			}
 		}
 	}
 	return res
@@ -0,0 +1,279 @@
+// BK-tree, a metric tree of the keys for the edit-distance (Levenshtein) search.
+// Refer to https://en.wikipedia.org/wiki/BK-tree
+package sources
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/util"
+)
+
+// FuzzyDistance is the max edit distance of the keys found by the fuzzy searcher, see NewBKTree.
+// The fuzzy searcher is used instead of the others if it's positive, it's set by "-fuzzy" or "fuzzy" in config.json.
+var FuzzyDistance = 0
+
+// bkNode is a node of BKTree, the children are in a singly linked list to keep the tree small.
+type bkNode struct {
+	word  string // the lowercase key
+	freq  uint32
+	dist  uint32 // the distance to the parent
+	child int32  // the first child, -1 if there is none
+	next  int32  // the next sibling, -1 if there is none
+}
+
+// BKTree is a Searcher which tolerates typos, it finds the keys within FuzzyDistance of the input,
+// ranked by the distance and the word frequency, see Candidates.
+type BKTree struct {
+	dict  Dict
+	max   int
+	nodes []bkNode
+	cased map[string][]string // the lowercase key -> keys, only for the keys which are not in lowercase
+}
+
+// Candidate is a key found by BKTree.
+type Candidate struct {
+	Word     string // the lowercase key
+	Distance int
+	Freq     int
+}
+
+// NewBKTree builds a BKTree of the keys of dict, which finds the keys within distance max.
+func NewBKTree(dict Dict, max int) *BKTree {
+	keys := dict.Keys()
+	t := &BKTree{dict: dict, max: max, cased: make(map[string][]string)}
+	lower := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lk := strings.ToLower(k)
+		if lk != k && !containsString(t.cased[lk], k) {
+			t.cased[lk] = append(t.cased[lk], k)
+		}
+		lower = append(lower, lk)
+	}
+	for _, k := range keys {
+		if cased, ok := t.cased[k]; ok && !containsString(cased, k) {
+			t.cased[k] = append(cased, k)
+		}
+	}
+	for _, cased := range t.cased {
+		sort.Strings(cased)
+	}
+	sort.Strings(lower)
+	freqs := wordFrequencies()
+	t.nodes = make([]bkNode, 0, len(lower))
+	for i, w := range lower {
+		if i > 0 && w == lower[i-1] {
+			continue
+		}
+		t.insert(w, uint32(freqs[w]))
+	}
+	log.Debugf("build bk-tree of %d keys, %d nodes", len(keys), len(t.nodes))
+	return t
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *BKTree) insert(w string, freq uint32) {
+	n := bkNode{word: w, freq: freq, child: -1, next: -1}
+	if len(t.nodes) == 0 {
+		t.nodes = append(t.nodes, n)
+		return
+	}
+	dist := newDistancer(w)
+	cur := int32(0)
+	for {
+		d := dist.distance(t.nodes[cur].word)
+		if d == 0 {
+			return
+		}
+		c := t.nodes[cur].child
+		for c >= 0 && t.nodes[c].dist != uint32(d) {
+			c = t.nodes[c].next
+		}
+		if c < 0 {
+			n.dist = uint32(d)
+			n.next = t.nodes[cur].child
+			t.nodes = append(t.nodes, n)
+			t.nodes[cur].child = int32(len(t.nodes) - 1)
+			return
+		}
+		cur = c
+	}
+}
+
+// Candidates returns the keys within the max distance of word, ranked by the distance, then the frequency.
+// The distance is at most a half of the length of word, so that short words don't match everything.
+func (t *BKTree) Candidates(word string) []Candidate {
+	word = strings.ToLower(word)
+	max := t.max
+	if n := utf8.RuneCountInString(word) / 2; n < max {
+		max = n
+	}
+	if len(t.nodes) == 0 {
+		return nil
+	}
+	var res []Candidate
+	dist := newDistancer(word)
+	stack := []int32{0}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := &t.nodes[cur]
+		d := dist.distance(n.word)
+		if d <= max {
+			res = append(res, Candidate{n.word, d, int(n.freq)})
+		}
+		for c := n.child; c >= 0; c = t.nodes[c].next {
+			if cd := int(t.nodes[c].dist); cd >= d-max && cd <= d+max {
+				stack = append(stack, c)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Distance != res[j].Distance {
+			return res[i].Distance < res[j].Distance
+		}
+		if res[i].Freq != res[j].Freq {
+			return res[i].Freq > res[j].Freq
+		}
+		return res[i].Word < res[j].Word
+	})
+	return res
+}
+
+// keys returns the keys of the lowercase key w.
+func (t *BKTree) keys(w string) []string {
+	if cased, ok := t.cased[w]; ok {
+		return cased
+	}
+	return []string{w}
+}
+
+// GetRawOutputs returns the definitions of the best candidate of input.
+func (t *BKTree) GetRawOutputs(input string) []RawOutput {
+	candidates := t.Candidates(input)
+	if len(candidates) == 0 {
+		return nil
+	}
+	var res []RawOutput
+	for _, k := range t.keys(candidates[0].Word) {
+		for _, def := range getAll(t.dict, k) {
+			res = append(res, output{k, def})
+		}
+	}
+	return res
+}
+
+// distancer computes the edit distances (in runes) to a word, the buffers are reused.
+type distancer struct {
+	a   []rune
+	b   []rune
+	row []int
+}
+
+func newDistancer(word string) *distancer {
+	return &distancer{a: []rune(word)}
+}
+
+// distance returns the Levenshtein distance between the word and b.
+func (d *distancer) distance(b string) int {
+	d.b = d.b[:0]
+	for _, r := range b {
+		d.b = append(d.b, r)
+	}
+	a, rb := d.a, d.b
+	if len(a) == 0 || len(rb) == 0 {
+		return len(a) + len(rb)
+	}
+	if cap(d.row) < len(rb)+1 {
+		d.row = make([]int, len(rb)+1)
+	}
+	row := d.row[:len(rb)+1]
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0] // the distance of a[:i-1] and b[:j-1]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cur := row[j]
+			cost := 1
+			if a[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = prev + cost
+			if row[j-1]+1 < row[j] {
+				row[j] = row[j-1] + 1
+			}
+			if cur+1 < row[j] {
+				row[j] = cur + 1
+			}
+			prev = cur
+		}
+	}
+	return row[len(rb)]
+}
+
+var freqOnce sync.Once
+var freqs map[string]int
+
+// wordFrequencies loads "frequency.txt" in the config directory, which has a word and optionally its count in each line,
+// in the descending order of the frequencies. It's empty if there is no such file.
+func wordFrequencies() map[string]int {
+	freqOnce.Do(func() {
+		freqs = loadFrequencies(util.FrequencyFile())
+	})
+	return freqs
+}
+
+func loadFrequencies(file string) map[string]int {
+	res := make(map[string]int)
+	f, err := os.Open(file)
+	if err != nil {
+		log.Debugf("load word frequencies err: %v", err)
+		return res
+	}
+	defer f.Close()
+	var words []string
+	var counts []int
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil || len(fields) == 1 {
+			n = -1
+		} else {
+			fields = fields[:len(fields)-1]
+		}
+		words = append(words, strings.ToLower(strings.Join(fields, " ")))
+		counts = append(counts, n)
+	}
+	for i, w := range words {
+		n := counts[i]
+		if n < 0 {
+			n = len(words) - i // ranked by the order of the lines
+		}
+		if n > res[w] {
+			res[w] = n
+		}
+	}
+	log.Debugf("load %d word frequencies from %v", len(res), file)
+	return res
+}
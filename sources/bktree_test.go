@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_distance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"docter", "doctor", 1},
+		{"recieve", "receive", 2},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+		{"flaw", "lawn", 2},
+	} {
+		assert.Equal(t, c.d, newDistancer(c.a).distance(c.b), "%q, %q", c.a, c.b)
+		assert.Equal(t, c.d, newDistancer(c.b).distance(c.a), "%q, %q", c.b, c.a)
+	}
+}
+
+func Test_BKTree(t *testing.T) {
+	old := freqs
+	defer func() { freqs = old }()
+	freqOnce.Do(func() {})
+	freqs = map[string]int{"doctor": 10, "docker": 5}
+
+	dict := Map{"doctor": "a doctor", "Docker": "a container", "docket": "a list", "receive": "to get", "August": "a month", "august": "grand"}
+	bk := NewBKTree(dict, 2)
+	assert.Equal(t, []Candidate{{"docker", 1, 5}, {"docket", 1, 0}}, bk.Candidates("Docke"))
+	assert.Equal(t, []Candidate{{"doctor", 1, 10}, {"docker", 1, 5}, {"docket", 2, 0}}, bk.Candidates("docter"))
+	assert.Empty(t, bk.Candidates("do"), "short words only match the close keys")
+
+	assert.Equal(t, []RawOutput{output{"receive", "to get"}}, bk.GetRawOutputs("recieve"))
+	assert.Equal(t, []RawOutput{output{"Docker", "a container"}}, bk.GetRawOutputs("docker"))
+	assert.Equal(t, []RawOutput{output{"August", "a month"}, output{"august", "grand"}}, bk.GetRawOutputs("augst"))
+	assert.Empty(t, bk.GetRawOutputs("nothing"))
+	assert.Empty(t, NewBKTree(Map{}, 2).GetRawOutputs("nothing"))
+}
+
+func Test_BKTreeBruteForce(t *testing.T) {
+	dict := Map{}
+	for i := 0; i < 2000; i++ {
+		dict[fmt.Sprintf("w%dx%d", i*7919%1000, i%37)] = ""
+	}
+	bk := NewBKTree(dict, 2)
+	for _, q := range []string{"w123x4", "w99x36", "w5x5", "x12w3"} {
+		want := map[string]bool{}
+		for k := range dict {
+			if newDistancer(q).distance(k) <= 2 {
+				want[k] = true
+			}
+		}
+		got := map[string]bool{}
+		for _, c := range bk.Candidates(q) {
+			got[c.Word] = true
+		}
+		assert.Equal(t, want, got, q)
+	}
+}
+
+func Test_loadFrequencies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "frequency.txt")
+	assert.Nil(t, os.WriteFile(file, []byte("the\nof 100\n\nice cream\nThe 2\n"), 0o644))
+	assert.Equal(t, map[string]int{"the": 4, "of": 100, "ice cream": 2}, loadFrequencies(file))
+	assert.Empty(t, loadFrequencies(filepath.Join(t.TempDir(), "nothing.txt")))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 
 	log "github.com/sirupsen/logrus"
 
@@ -15,10 +16,34 @@ type DictConfig struct {
 	Name string
 	Css  string
 	Type string
+	// Format is the format of the dictionary files, "mdx" (the default, which also loads "<name>.json"),
+	// "stardict" for "<name>.ifo", "<name>.idx", "<name>.dict(.dz)" and "<name>.syn",
+	// or "dsl" for "<name>.dsl(.dz)", whose Type is "DSL" if it's empty.
+	// For the dictionaries whose Type is "glossary", it's "json" or "tsv" instead, see Glossary.
+	Format string
+	// UserID and RegCode are for registered dictionaries with encrypted headers,
+	// UserID is the email or the device id you registered with, RegCode is the hex-encoded registration code.
+	UserID  string
+	RegCode string
+	// StyleSheet expands the `N` style tags in the records with the StyleSheet in the MDX header.
+	StyleSheet bool
+	// Enabled is true if it's absent, the disabled dictionaries are not loaded.
+	Enabled *bool
+	// Priority decides the order of the results, the dictionaries of higher priorities come first,
+	// those of the same priority are in the order of the config.
+	Priority int
+	// Alias is another name to select the dictionary with, besides its Name, see Dicts.Select.
+	Alias string
 }
 
 type Config struct {
 	Dicts []DictConfig `json:"dicts"`
+	// Engines are the sources queried in order when no engine is specified, see DefaultEngines.
+	Engines []string `json:"engines"`
+	// Fuzzy is the max edit distance of the fuzzy search, see FuzzyDistance.
+	Fuzzy int `json:"fuzzy"`
+	// FullText builds the full-text indexes when the server starts, see FullText.
+	FullText bool `json:"fulltext"`
 }
 
 func LoadConfig() error {
@@ -32,14 +57,33 @@ func LoadConfig() error {
 		log.Debugf("bad json unmarshal: %v, default settings are used.", err)
 		return err
 	}
+	if len(c.Engines) > 0 {
+		DefaultEngines = c.Engines
+	}
+	if c.Fuzzy > 0 && FuzzyDistance == 0 {
+		FuzzyDistance = c.Fuzzy
+	}
+	if c.FullText {
+		FullText = true
+	}
 	if len(c.Dicts) == 0 {
 		return nil
 	}
+	sort.SliceStable(c.Dicts, func(i, j int) bool { return c.Dicts[i].Priority > c.Dicts[j].Priority })
 	for _, d := range c.Dicts {
+		if d.Enabled != nil && !*d.Enabled {
+			log.Debugf("dict %v is disabled", d.Name)
+			continue
+		}
 		dict := &MdxDict{}
 		dict.MdxFile = filepath.Join(util.DictsPath(), d.Name)
 		dict.MdxCss = filepath.Join(util.DictsPath(), d.Css+".css")
 		dict.Type = d.Type
+		dict.Format = d.Format
+		dict.UserID = d.UserID
+		dict.RegCode = d.RegCode
+		dict.StyleSheet = d.StyleSheet
+		dict.Alias = d.Alias
 		log.Debugf("get global dict: %v", dict.MdxFile)
 		*G = append(*G, dict)
 	}
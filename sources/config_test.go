@@ -0,0 +1,58 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	home, _ := os.UserHomeDir()
+	config := `{
+  "engines": ["glossary", "mdx"],
+  "fuzzy": 2,
+  "fulltext": true,
+  "dicts": [
+    {"name": "a"},
+    {"name": "b", "priority": 2, "alias": "bee"},
+    {"name": "c", "enabled": false, "priority": 3},
+    {"name": "d", "enabled": true, "priority": 2}
+  ]
+}`
+	assert.Nil(t, os.MkdirAll(filepath.Join(home, ".config", "ondict"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(home, ".config", "ondict", "config.json"), []byte(config), 0o644))
+	oldG, oldEngines, oldFuzzy, oldFullText := *G, DefaultEngines, FuzzyDistance, FullText
+	defer func() { *G, DefaultEngines, FuzzyDistance, FullText = oldG, oldEngines, oldFuzzy, oldFullText }()
+	*G = Dicts{}
+
+	assert.Nil(t, LoadConfig())
+	assert.Equal(t, []string{"glossary", "mdx"}, DefaultEngines)
+	assert.Equal(t, 2, FuzzyDistance)
+	assert.True(t, FullText)
+	var names []string
+	for _, d := range *G {
+		names = append(names, d.Name())
+	}
+	assert.Equal(t, []string{"b", "d", "a"}, names)
+	assert.Equal(t, "bee", (*G)[0].Alias)
+}
+
+func Test_DictsSelect(t *testing.T) {
+	a := &MdxDict{MdxFile: "/dicts/oald9"}
+	b := &MdxDict{MdxFile: "/dicts/LDOCE5++ V 1-35", Alias: "ldoce"}
+	c := &MdxDict{MdxFile: "/dicts/team"}
+	g := &Dicts{a, b, c}
+
+	assert.Equal(t, g, g.Select(nil))
+	assert.Equal(t, &Dicts{b, a}, g.Select(DictNames(" LDOCE , oald9,")))
+	assert.Equal(t, &Dicts{b}, g.Select([]string{"ldoce5++ v 1-35", "ldoce"}))
+	assert.Equal(t, &Dicts{c}, g.Select([]string{"nothing", "team"}))
+	assert.Equal(t, &Dicts{}, g.Select([]string{"nothing"}))
+
+	l := (&Local{Dicts: g, MDD: true}).Select([]string{"team"}).(*Local)
+	assert.Equal(t, &Dicts{c}, l.Dicts)
+	assert.True(t, l.MDD, "the load options are kept")
+}
@@ -0,0 +1,25 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ChaosNyaruko/ondict/dsl"
+	"github.com/ChaosNyaruko/ondict/render"
+)
+
+func (d *MdxDict) loadDSL() (Dict, error) {
+	name := d.MdxFile + ".dsl"
+	if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+		name += ".dz"
+	}
+	dict, err := dsl.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("load dsl %v err: %w", d.MdxFile, err)
+	}
+	if d.Type == "" {
+		d.Type = render.DSL
+	}
+	return dict, nil
+}
@@ -0,0 +1,30 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ChaosNyaruko/ondict/render"
+)
+
+func Test_RegisterDSL(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "lingvo")
+	assert.Nil(t, os.WriteFile(base+".dsl", []byte("#NAME \"Lingvo\"\ndoctor\n\t[m1][trn]врач[/trn], see <<nurse>>[/m]\n"), 0o644))
+	d := &MdxDict{MdxFile: base, Format: "dsl"}
+	assert.Nil(t, d.Register(true, false))
+	assert.Equal(t, render.DSL, d.Type)
+	assert.Equal(t, "Lingvo", d.Info().DSL.Name)
+	assert.Equal(t, 1, d.Info().Entries)
+
+	old := *G
+	defer func() { *G = old }()
+	*G = Dicts{d}
+	html := QueryMDX("doctor", "html")
+	assert.Contains(t, html, `<span class="dsl_trn">врач</span>`)
+	assert.Contains(t, html, `href="/dict?query=nurse&amp;engine=mdx&amp;format=html&amp;dict=lingvo"`)
+	assert.Equal(t, "**doctor**\n  врач, see nurse", strings.TrimPrefix(QueryMDX("doctor", "md"), "\n---\n"))
+}
@@ -9,10 +9,13 @@ func NewExact(dict Dict) Searcher {
 }
 
 func (e *Exact) GetRawOutputs(input string) []RawOutput {
-	res := make([]RawOutput, 0, 1)
-	res = append(res, output{
-		rawWord: input,
-		def:     e.dict.Get(input),
-	})
+	defs := getAll(e.dict, input)
+	res := make([]RawOutput, 0, len(defs))
+	for _, def := range defs {
+		res = append(res, output{
+			rawWord: input,
+			def:     def,
+		})
+	}
 	return res
 }
@@ -0,0 +1,152 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Glossary is a hand-maintained dictionary, whose definitions are markdown or plain text.
+// It's a JSON object of "term": "definition", or a TSV file of "term<TAB>definition" lines,
+// in which "\n", "\t" and "\\" are escaped, and the lines starting with "#" are comments.
+// The file is reloaded when it's modified.
+type Glossary struct {
+	file   string
+	format string // "json" or "tsv"
+
+	mu      sync.RWMutex // owns the fields below
+	entries map[string]string
+	lower   map[string][]string // the lowercase term -> terms
+	modTime time.Time
+	size    int64
+}
+
+// NewGlossary loads the glossary file in format, which is "json" or "tsv".
+func NewGlossary(file string, format string) (*Glossary, error) {
+	format = strings.ToLower(format)
+	if format != "json" && format != "tsv" {
+		return nil, fmt.Errorf("unknown glossary format %q, only json and tsv are supported", format)
+	}
+	g := &Glossary{file: file, format: format}
+	if _, err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload reloads the file if it's modified since the last load, and reports whether it's reloaded.
+// The old entries are kept if the file is broken.
+func (g *Glossary) Reload() (bool, error) {
+	stat, err := os.Stat(g.file)
+	if err != nil {
+		return false, err
+	}
+	g.mu.RLock()
+	unchanged := stat.ModTime().Equal(g.modTime) && stat.Size() == g.size
+	g.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	data, err := os.ReadFile(g.file)
+	if err != nil {
+		return false, err
+	}
+	var entries map[string]string
+	if g.format == "json" {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		entries, err = parseTSV(data)
+	}
+	if err != nil {
+		return false, fmt.Errorf("parse glossary %v err: %v", g.file, err)
+	}
+	lower := make(map[string][]string, len(entries))
+	for k := range entries {
+		lk := strings.ToLower(k)
+		lower[lk] = append(lower[lk], k)
+	}
+	for _, terms := range lower {
+		sort.Strings(terms)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.entries, g.lower = entries, lower
+	g.modTime, g.size = stat.ModTime(), stat.Size()
+	log.Debugf("load glossary %v, %d entries", g.file, len(entries))
+	return true, nil
+}
+
+var tsvUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+
+// parseTSV parses the "term<TAB>definition" lines, the definitions of the same term are joined.
+func parseTSV(data []byte) (map[string]string, error) {
+	entries := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		term, def, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("no tab in line %d", n)
+		}
+		term = strings.TrimSpace(tsvUnescaper.Replace(term))
+		def = tsvUnescaper.Replace(def)
+		if old, ok := entries[term]; ok {
+			def = old + "\n\n" + def
+		}
+		entries[term] = def
+	}
+	return entries, s.Err()
+}
+
+func (g *Glossary) Keys() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	res := make([]string, 0, len(g.entries))
+	for k := range g.entries {
+		res = append(res, k)
+	}
+	return res
+}
+
+// Get returns the definition of word, it's case-insensitive if there is no exact match.
+func (g *Glossary) Get(word string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if def, ok := g.entries[word]; ok {
+		return def
+	}
+	var defs []string
+	for _, k := range g.lower[strings.ToLower(word)] {
+		defs = append(defs, g.entries[k])
+	}
+	return strings.Join(defs, "\n\n")
+}
+
+// loadGlossary loads "<name>.tsv" or "<name>.json", Format decides which one if it's set.
+func (d *MdxDict) loadGlossary() (Dict, error) {
+	format := strings.ToLower(d.Format)
+	if format == "" {
+		format = "json"
+		if _, err := os.Stat(d.MdxFile + ".tsv"); !errors.Is(err, os.ErrNotExist) {
+			format = "tsv"
+		}
+	}
+	g, err := NewGlossary(d.MdxFile+"."+format, format)
+	if err != nil {
+		return nil, fmt.Errorf("load glossary %v err: %w", d.MdxFile, err)
+	}
+	return g, nil
+}
@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseTSV(t *testing.T) {
+	entries, err := parseTSV([]byte("# team glossary\r\nSLO\tService Level Objective\r\n\nLGTM\tlooks good\\nto me\nSLO\tsee also `SLA`\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"SLO":  "Service Level Objective\n\nsee also `SLA`",
+		"LGTM": "looks good\nto me",
+	}, entries)
+	_, err = parseTSV([]byte("no tab here\n"))
+	assert.NotNil(t, err)
+}
+
+func Test_GlossaryReload(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "team")
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("SLO\t**Service** Level Objective\n"), 0o644))
+	d := &MdxDict{MdxFile: base, Type: "glossary"}
+	assert.Nil(t, d.Register(false, false))
+	assert.Equal(t, []string{"**Service** Level Objective"}, d.Get("slo"))
+	assert.Equal(t, 1, d.Info().Entries)
+
+	// a broken file keeps the old entries
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("broken\n"), 0o644))
+	assert.Nil(t, os.Chtimes(base+".tsv", time.Now(), time.Now().Add(time.Second)))
+	assert.Equal(t, []string{"**Service** Level Objective"}, d.Get("slo"))
+
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("SLO\tService Level Objective\nerror budget\t1 - SLO\n"), 0o644))
+	assert.Nil(t, os.Chtimes(base+".tsv", time.Now(), time.Now().Add(2*time.Second)))
+	assert.Equal(t, []string{"1 - SLO"}, d.Get("what is the error budget"))
+	assert.Equal(t, 2, d.Info().Entries)
+
+	old := *G
+	defer func() { *G = old }()
+	*G = Dicts{d}
+	assert.Equal(t, "\n---\n1 - SLO", QueryMDX("error budget", "md"))
+	assert.Contains(t, QueryMDX("error budget", "html"), "<p>1 - SLO</p>")
+}
+
+func Test_GlossaryJSON(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "team")
+	assert.Nil(t, os.WriteFile(base+".json", []byte(`{"LGTM": "looks good to me", "lgtm": "the same"}`), 0o644))
+	d := &MdxDict{MdxFile: base, Type: "glossary"}
+	assert.Nil(t, d.Register(true, false))
+	assert.Equal(t, []string{"the same"}, d.Get("LGTM"))
+	g := d.MdxDict.(*Glossary)
+	assert.Equal(t, "looks good to me", g.Get("LGTM"))
+	assert.Equal(t, "looks good to me\n\nthe same", g.Get("Lgtm"))
+
+	_, err := NewGlossary(base+".json", "yaml")
+	assert.NotNil(t, err)
+	d = &MdxDict{MdxFile: base, Type: "glossary", Format: "tsv"}
+	assert.NotNil(t, d.Register(true, false))
+}
@@ -0,0 +1,72 @@
+package sources
+
+import (
+	"path/filepath"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+	"github.com/ChaosNyaruko/ondict/dsl"
+	"github.com/ChaosNyaruko/ondict/stardict"
+)
+
+// DictInfo is the metadata of a loaded dictionary.
+type DictInfo struct {
+	Name  string `json:"name"`
+	Alias string `json:"alias,omitempty"`
+	Type  string `json:"type"`
+	// Css is the CSS file bound to the dictionary, empty if all the CSS files in the dicts directory are used.
+	Css     string `json:"css"`
+	Entries int    `json:"entries"`
+	// MDX is the metadata of the MDX file, nil if the dictionary is loaded from JSON.
+	MDX *decoder.Info `json:"mdx,omitempty"`
+	// StarDict is the metadata of the StarDict dictionary, nil if it's not.
+	StarDict *stardict.Info `json:"stardict,omitempty"`
+	// DSL is the metadata of the DSL dictionary, nil if it's not.
+	DSL *dsl.Info `json:"dsl,omitempty"`
+}
+
+type infoer interface {
+	Info() decoder.Info
+}
+
+// Name is the name of d, i.e. the base name of its files.
+func (d *MdxDict) Name() string {
+	return filepath.Base(d.MdxFile)
+}
+
+// Info returns the metadata of d, it should be registered.
+func (d *MdxDict) Info() DictInfo {
+	info := DictInfo{
+		Name:  d.Name(),
+		Alias: d.Alias,
+		Type:  d.Type,
+		Css:   d.cssFile,
+	}
+	switch dict := d.MdxDict.(type) {
+	case infoer:
+		mdx := dict.Info()
+		info.MDX = &mdx
+		info.Entries = mdx.Entries
+	case *stardict.Dict:
+		sd := dict.Info()
+		info.StarDict = &sd
+		info.Entries = len(dict.Keys())
+	case *dsl.Dict:
+		meta := dict.Info()
+		info.DSL = &meta
+		info.Entries = len(dict.Keys())
+	case *Glossary:
+		info.Entries = len(dict.Keys())
+	case Map:
+		info.Entries = len(dict)
+	}
+	return info
+}
+
+// Info returns the metadata of all the loaded dictionaries.
+func (g *Dicts) Info() []DictInfo {
+	res := make([]DictInfo, 0, len(*g))
+	for _, d := range *g {
+		res = append(res, d.Info())
+	}
+	return res
+}
@@ -0,0 +1,39 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+func Test_DictsInfo(t *testing.T) {
+	dir := t.TempDir()
+	fd, err := os.Create(filepath.Join(dir, "glossary.mdx"))
+	assert.Nil(t, err)
+	e := decoder.Encoder{Header: decoder.Header{Title: "Team Glossary"}}
+	assert.Nil(t, e.WriteMDX(fd, map[string]string{"ack": "acknowledgement", "lgtm": "looks good to me"}))
+	assert.Nil(t, fd.Close())
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "glossary.css"), []byte("b { color: red; }"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "terms.json"), []byte(`{"wip": "work in progress"}`), 0o644))
+
+	mdx := &MdxDict{MdxFile: filepath.Join(dir, "glossary"), MdxCss: filepath.Join(dir, "glossary.css"), Type: "Glossary"}
+	assert.Nil(t, mdx.Register(true, false))
+	json := &MdxDict{MdxFile: filepath.Join(dir, "terms"), MdxCss: filepath.Join(dir, "terms.css")}
+	assert.Nil(t, json.Register(true, false))
+
+	infos := (&Dicts{mdx, json}).Info()
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "glossary", infos[0].Name)
+	assert.Equal(t, "Glossary", infos[0].Type)
+	assert.Equal(t, filepath.Join(dir, "glossary.css"), infos[0].Css)
+	assert.Equal(t, 2, infos[0].Entries)
+	assert.Equal(t, "Team Glossary", infos[0].MDX.Title)
+	assert.Equal(t, "terms", infos[1].Name)
+	assert.Equal(t, "", infos[1].Css)
+	assert.Equal(t, 1, infos[1].Entries)
+	assert.Nil(t, infos[1].MDX)
+}
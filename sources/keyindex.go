@@ -0,0 +1,89 @@
+package sources
+
+import (
+	"sort"
+	"strings"
+)
+
+// KeyIndex is a sorted index of the case-folded keys of a dictionary, for the prefix completion.
+type KeyIndex struct {
+	folds []string // the sorted lowercase keys
+	words []string // the keys, in the same order of folds
+}
+
+// NewKeyIndex builds the KeyIndex of keys, the duplicated ones are dropped.
+func NewKeyIndex(keys []string) *KeyIndex {
+	type key struct{ fold, word string }
+	sorted := make([]key, 0, len(keys))
+	for _, k := range keys {
+		sorted = append(sorted, key{strings.ToLower(k), k})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].fold != sorted[j].fold {
+			return sorted[i].fold < sorted[j].fold
+		}
+		return sorted[i].word < sorted[j].word
+	})
+	x := &KeyIndex{folds: make([]string, 0, len(sorted)), words: make([]string, 0, len(sorted))}
+	for i, k := range sorted {
+		if i > 0 && k.word == sorted[i-1].word {
+			continue
+		}
+		x.folds = append(x.folds, k.fold)
+		x.words = append(x.words, k.word)
+	}
+	return x
+}
+
+// Complete returns at most limit keys starting with prefix case-insensitively, in the order of the lowercase keys.
+func (x *KeyIndex) Complete(prefix string, limit int) []string {
+	prefix = strings.ToLower(prefix)
+	var res []string
+	for i := sort.SearchStrings(x.folds, prefix); i < len(x.folds) && len(res) < limit; i++ {
+		if !strings.HasPrefix(x.folds[i], prefix) {
+			break
+		}
+		res = append(res, x.words[i])
+	}
+	return res
+}
+
+// Lookup returns the keys equal to word case-insensitively.
+func (x *KeyIndex) Lookup(word string) []string {
+	fold := strings.ToLower(word)
+	var res []string
+	for i := sort.SearchStrings(x.folds, fold); i < len(x.folds) && x.folds[i] == fold; i++ {
+		res = append(res, x.words[i])
+	}
+	return res
+}
+
+// keyIndex returns the KeyIndex of d, which is built on demand.
+func (d *MdxDict) keyIndex() *KeyIndex {
+	d.reload()
+	d.mu.RLock()
+	x := d.keys
+	d.mu.RUnlock()
+	if x != nil {
+		return x
+	}
+	x = NewKeyIndex(d.MdxDict.Keys())
+	d.mu.Lock()
+	d.keys = x
+	d.mu.Unlock()
+	return x
+}
+
+// Complete returns at most limit keys of all the dictionaries of g starting with prefix case-insensitively,
+// see KeyIndex.Complete.
+func (g *Dicts) Complete(prefix string, limit int) []string {
+	var all []string
+	for _, d := range *g {
+		all = append(all, d.keyIndex().Complete(prefix, limit)...)
+	}
+	res := NewKeyIndex(all).words
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
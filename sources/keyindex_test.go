@@ -0,0 +1,34 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KeyIndex(t *testing.T) {
+	x := NewKeyIndex([]string{"doctor", "Docker", "doc", "August", "august", "dock", "doctor", "égal"})
+	assert.Equal(t, []string{"doc", "dock", "Docker", "doctor"}, x.Complete("DOC", 10))
+	assert.Equal(t, []string{"doc", "dock"}, x.Complete("doc", 2))
+	assert.Equal(t, []string{"August", "august"}, x.Complete("aug", 10))
+	assert.Equal(t, []string{"égal"}, x.Complete("ÉG", 10))
+	assert.Empty(t, x.Complete("x", 10))
+	assert.Empty(t, NewKeyIndex(nil).Complete("a", 10))
+	assert.Equal(t, []string{"August", "august"}, x.Lookup("AUGUST"))
+	assert.Empty(t, x.Lookup("docto"))
+}
+
+func Test_DictsComplete(t *testing.T) {
+	newDict := func(name string, dict Map) *MdxDict {
+		d := &MdxDict{MdxFile: "/dicts/" + name, MdxDict: dict}
+		d.searcher = d.newSearcher()
+		return d
+	}
+	a := newDict("a", Map{"doctor": "1", "dock": "2"})
+	b := newDict("b", Map{"doctor": "3", "docile": "4", "go": "5"})
+	g := &Dicts{a, b}
+	assert.Equal(t, []string{"dock", "doctor"}, a.keyIndex().Complete("do", 10))
+	assert.Equal(t, []string{"docile", "dock", "doctor"}, g.Complete("doc", 10))
+	assert.Equal(t, []string{"docile", "dock"}, g.Complete("doc", 2))
+	assert.Equal(t, []string{}, g.Complete("x", 2))
+}
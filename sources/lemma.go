@@ -0,0 +1,185 @@
+package sources
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/util"
+)
+
+// irregulars are the common irregular English inflections, form -> lemmas.
+var irregulars = map[string][]string{
+	// verbs
+	"am": {"be"}, "is": {"be"}, "are": {"be"}, "was": {"be"}, "were": {"be"}, "been": {"be"}, "being": {"be"},
+	"has": {"have"}, "had": {"have"}, "does": {"do"}, "did": {"do"}, "done": {"do"},
+	"went": {"go"}, "gone": {"go"}, "goes": {"go"},
+	"ate": {"eat"}, "eaten": {"eat"}, "began": {"begin"}, "begun": {"begin"},
+	"bought": {"buy"}, "brought": {"bring"}, "built": {"build"}, "caught": {"catch"},
+	"came": {"come"}, "chose": {"choose"}, "chosen": {"choose"}, "drank": {"drink"}, "drunk": {"drink"},
+	"drew": {"draw"}, "drawn": {"draw"}, "drove": {"drive"}, "driven": {"drive"},
+	"fell": {"fall"}, "fallen": {"fall"}, "felt": {"feel"}, "fought": {"fight"}, "found": {"find"},
+	"flew": {"fly"}, "flown": {"fly"}, "forgot": {"forget"}, "forgotten": {"forget"},
+	"gave": {"give"}, "given": {"give"}, "got": {"get"}, "gotten": {"get"}, "grew": {"grow"}, "grown": {"grow"},
+	"heard": {"hear"}, "held": {"hold"}, "kept": {"keep"}, "knew": {"know"}, "known": {"know"},
+	"laid": {"lay"}, "lain": {"lie"}, "lay": {"lie"}, "led": {"lead"}, "left": {"leave"}, "lent": {"lend"}, "lost": {"lose"},
+	"made": {"make"}, "meant": {"mean"}, "met": {"meet"}, "paid": {"pay"},
+	"ran": {"run"}, "rang": {"ring"}, "rung": {"ring"}, "rode": {"ride"}, "ridden": {"ride"},
+	"rose": {"rise"}, "risen": {"rise"}, "said": {"say"}, "sang": {"sing"}, "sung": {"sing"},
+	"sat": {"sit"}, "saw": {"see"}, "seen": {"see"}, "sold": {"sell"}, "sent": {"send"},
+	"shook": {"shake"}, "shaken": {"shake"}, "shot": {"shoot"}, "slept": {"sleep"},
+	"spoke": {"speak"}, "spoken": {"speak"}, "spent": {"spend"}, "stood": {"stand"}, "stole": {"steal"}, "stolen": {"steal"},
+	"swam": {"swim"}, "swum": {"swim"}, "took": {"take"}, "taken": {"take"}, "taught": {"teach"},
+	"thought": {"think"}, "threw": {"throw"}, "thrown": {"throw"}, "told": {"tell"}, "understood": {"understand"},
+	"woke": {"wake"}, "woken": {"wake"}, "wore": {"wear"}, "worn": {"wear"}, "won": {"win"},
+	"wrote": {"write"}, "written": {"write"}, "broke": {"break"}, "broken": {"break"},
+	"froze": {"freeze"}, "frozen": {"freeze"}, "hid": {"hide"}, "hidden": {"hide"}, "bit": {"bite"}, "bitten": {"bite"},
+	"blew": {"blow"}, "blown": {"blow"}, "fed": {"feed"}, "fled": {"flee"}, "dealt": {"deal"}, "dug": {"dig"},
+	"sought": {"seek"}, "struck": {"strike"}, "swore": {"swear"}, "sworn": {"swear"}, "tore": {"tear"}, "torn": {"tear"},
+	"wept": {"weep"}, "wound": {"wind"}, "lit": {"light"}, "slid": {"slide"}, "stuck": {"stick"}, "stung": {"sting"},
+	// nouns
+	"men": {"man"}, "women": {"woman"}, "children": {"child"}, "people": {"person"},
+	"mice": {"mouse"}, "lice": {"louse"}, "geese": {"goose"}, "feet": {"foot"}, "teeth": {"tooth"},
+	"oxen": {"ox"}, "dice": {"die"}, "criteria": {"criterion"}, "phenomena": {"phenomenon"},
+	"data": {"datum"}, "media": {"medium"}, "analyses": {"analysis"}, "crises": {"crisis"}, "theses": {"thesis"},
+	"cacti": {"cactus"}, "fungi": {"fungus"}, "nuclei": {"nucleus"}, "stimuli": {"stimulus"}, "indices": {"index"},
+	"appendices": {"appendix"}, "matrices": {"matrix"}, "vertices": {"vertex"},
+	// adjectives
+	"better": {"good", "well"}, "best": {"good", "well"}, "worse": {"bad", "ill"}, "worst": {"bad", "ill"},
+	"more": {"many", "much"}, "most": {"many", "much"}, "less": {"little"}, "least": {"little"},
+	"further": {"far"}, "furthest": {"far"}, "farther": {"far"}, "farthest": {"far"},
+}
+
+// suffixRules are the regular English inflections, the suffix of a form -> the suffixes of its lemma, in order.
+var suffixRules = []struct {
+	suffix string
+	lemmas []string
+}{
+	{"ies", []string{"y", "ie"}},
+	{"ied", []string{"y", "ie"}},
+	{"ier", []string{"y"}},
+	{"iest", []string{"y"}},
+	{"ves", []string{"f", "fe", "ve"}},
+	{"men", []string{"man"}},
+	{"ches", []string{"ch"}},
+	{"shes", []string{"sh"}},
+	{"sses", []string{"ss"}},
+	{"xes", []string{"x"}},
+	{"zes", []string{"z", "ze"}},
+	{"oes", []string{"o", "oe"}},
+	{"s", []string{""}},
+	{"ying", []string{"ie", "y"}},
+	{"ing", []string{"", "e"}},
+	{"ed", []string{"", "e"}},
+	{"est", []string{"", "e"}},
+	{"er", []string{"", "e"}},
+}
+
+// doubled returns the lemma of the forms with a doubled final consonant, e.g. "run" for "runn" in "running".
+func doubled(stem string) (string, bool) {
+	n := len(stem)
+	if n < 3 || stem[n-1] != stem[n-2] || strings.ContainsRune("aeiouwxy", rune(stem[n-1])) {
+		return "", false
+	}
+	return stem[:n-1], true
+}
+
+// cvc reports whether stem ends in a consonant, a vowel and a consonant, such as "hop" and "lik".
+func cvc(stem string) bool {
+	n := len(stem)
+	vowel := func(c byte) bool { return strings.IndexByte("aeiou", c) >= 0 }
+	return n >= 2 && !vowel(stem[n-1]) && strings.IndexByte("wxy", stem[n-1]) < 0 && vowel(stem[n-2]) && (n == 2 || !vowel(stem[n-3]))
+}
+
+var lemmaOnce sync.Once
+var lemmaList map[string][]string
+
+// lemmaFile loads "lemmas.txt" in the config directory, in which each line is "lemma -> form1,form2,...",
+// e.g. "go -> goes,going,went,gone", the lines starting with ";" are comments.
+func lemmaFile() map[string][]string {
+	lemmaOnce.Do(func() {
+		lemmaList = loadLemmas(util.LemmaFile())
+	})
+	return lemmaList
+}
+
+func loadLemmas(file string) map[string][]string {
+	res := make(map[string][]string)
+	f, err := os.Open(file)
+	if err != nil {
+		log.Debugf("load lemmas err: %v", err)
+		return res
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		lemma, forms, ok := strings.Cut(line, "->")
+		if !ok {
+			continue
+		}
+		lemma, _, _ = strings.Cut(strings.TrimSpace(lemma), "/") // "go/123", with the frequency
+		lemma = strings.ToLower(lemma)
+		for _, form := range strings.Split(forms, ",") {
+			if form = strings.ToLower(strings.TrimSpace(form)); form != "" && !containsString(res[form], lemma) {
+				res[form] = append(res[form], lemma)
+			}
+		}
+	}
+	log.Debugf("load lemmas of %d forms from %v", len(res), file)
+	return res
+}
+
+// Lemmas returns the candidates of the lemmas of an English word, the most likely first,
+// such as "go" for "went", "study" for "studies" and "run" for "running".
+// They come from "lemmas.txt" in the config directory, the irregular forms, then the regular suffix rules,
+// and they may not be words, so look them up and pick the first one which exists.
+func Lemmas(word string) []string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || strings.IndexFunc(word, func(r rune) bool { return !(r >= 'a' && r <= 'z' || r == '-' || r == '\'') }) >= 0 {
+		return nil
+	}
+	var res []string
+	add := func(lemma string) {
+		if len(lemma) > 1 && lemma != word && !containsString(res, lemma) {
+			res = append(res, lemma)
+		}
+	}
+	for _, lemma := range lemmaFile()[word] {
+		add(lemma)
+	}
+	for _, lemma := range irregulars[word] {
+		add(lemma)
+	}
+	for _, r := range suffixRules {
+		if !strings.HasSuffix(word, r.suffix) {
+			continue
+		}
+		stem := strings.TrimSuffix(word, r.suffix)
+		if stem == "" {
+			continue
+		}
+		lemmas := r.lemmas
+		if len(lemmas) == 2 && lemmas[0] == "" && cvc(stem) {
+			lemmas = []string{"e", ""} // "hoping" is more likely "hope" than "hop"
+		}
+		for _, l := range lemmas {
+			add(stem + l)
+		}
+		if s, ok := doubled(stem); ok && (r.suffix == "ing" || r.suffix == "ed" || r.suffix == "er" || r.suffix == "est") {
+			add(s)
+		}
+	}
+	return res
+}
+
+// lemmaNote notes that word is matched by its lemma, e.g. "went → go".
+func lemmaNote(word string, lemma string) string {
+	return strings.ToLower(strings.TrimSpace(word)) + " → " + lemma
+}
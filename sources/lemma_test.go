@@ -0,0 +1,110 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Lemmas(t *testing.T) {
+	old := lemmaList
+	defer func() { lemmaList = old }()
+	lemmaOnce.Do(func() {})
+	lemmaList = map[string][]string{"learnt": {"learn"}}
+
+	for _, c := range []struct {
+		word string
+		want string // the first one which is a word
+	}{
+		{"went", "go"},
+		{"Mice", "mouse"},
+		{"studies", "study"},
+		{"studied", "study"},
+		{"running", "run"},
+		{"stopped", "stop"},
+		{"hoping", "hope"},
+		{"walked", "walk"},
+		{"liked", "like"},
+		{"boxes", "box"},
+		{"wolves", "wolf"},
+		{"cats", "cat"},
+		{"bigger", "big"},
+		{"happiest", "happy"},
+		{"lying", "lie"},
+		{"learnt", "learn"},
+	} {
+		lemmas := Lemmas(c.word)
+		assert.NotEmpty(t, lemmas, c.word)
+		words := map[string]bool{"go": true, "mouse": true, "study": true, "stud": true, "run": true, "stop": true,
+			"hope": true, "hop": true, "walk": true, "like": true, "box": true, "wolf": true, "cat": true,
+			"big": true, "happy": true, "lie": true, "learn": true}
+		first := ""
+		for _, l := range lemmas {
+			if words[l] {
+				first = l
+				break
+			}
+		}
+		assert.Equal(t, c.want, first, "%s: %v", c.word, lemmas)
+	}
+	assert.Empty(t, Lemmas("error budget"))
+	assert.Empty(t, Lemmas("学习"))
+	assert.Empty(t, Lemmas("as"), "the single letters are not lemmas")
+}
+
+func Test_loadLemmas(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lemmas.txt")
+	assert.Nil(t, os.WriteFile(file, []byte("; comment\ngo/1234 -> goes,going,went,gone\nwend -> went\nbad\n"), 0o644))
+	assert.Equal(t, map[string][]string{
+		"goes":  {"go"},
+		"going": {"go"},
+		"went":  {"go", "wend"},
+		"gone":  {"go"},
+	}, loadLemmas(file))
+}
+
+func Test_MatchLemma(t *testing.T) {
+	for _, fzf := range []bool{true, false} {
+		d := &MdxDict{MdxFile: "test", Type: "glossary", MdxDict: Map{"go": "to move", "went": "see go", "stud": "a nail", "study": "to learn", "studies": "see study"}}
+		d.fzf = fzf
+		d.searcher = d.newSearcher()
+
+		res, lemma := d.Match("Studies")
+		assert.Equal(t, []RawOutput{output{"studies", "see study"}}, res, "the word itself is preferred")
+		assert.Equal(t, "", lemma)
+		d.MdxDict = Map{"go": "to move", "stud": "a nail", "study": "to learn"}
+		d.searcher = d.newSearcher()
+		res, lemma = d.Match("Studies")
+		assert.Equal(t, []RawOutput{output{"study", "to learn"}}, res)
+		assert.Equal(t, "study", lemma)
+		assert.Equal(t, []string{"to move"}, d.Get("went"))
+
+		g := &Dicts{d}
+		assert.Equal(t, "\n---\n*went → go*\nto move", g.Query("went", "md"))
+		assert.Contains(t, g.Query("went", "html"), `<div class="ondict-lemma">went → go</div>`)
+		assert.Equal(t, "go", g.Results("went")[0].Lemma)
+	}
+}
+
+// countingSearcher counts the records read by the searcher.
+type countingSearcher struct {
+	Searcher
+	calls int
+}
+
+func (s *countingSearcher) GetRawOutputs(input string) []RawOutput {
+	s.calls++
+	return s.Searcher.GetRawOutputs(input)
+}
+
+func Test_MatchLemmaKeys(t *testing.T) {
+	d := &MdxDict{MdxFile: "test", MdxDict: Map{"study": "to learn", "stud": "a nail", "studio": "a room"}}
+	s := &countingSearcher{Searcher: NewAho(d.MdxDict)}
+	d.searcher = s
+	res, lemma := d.Match("studies")
+	assert.Equal(t, []RawOutput{output{"study", "to learn"}}, res)
+	assert.Equal(t, "study", lemma)
+	assert.Equal(t, 1, s.calls, "the lemmas are checked by the keys")
+}
@@ -1,9 +1,31 @@
 package sources
 
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
 type Map map[string]string
 
+// Get returns the definition of word, "@@@LINK=" redirects are followed,
+// since the JSON files are usually dumped from MDX files.
 func (m Map) Get(word string) string {
-	return m[word]
+	def := m[word]
+	visited := map[string]bool{word: true}
+	for depth := 0; depth <= decoder.MaxLinkDepth; depth++ {
+		target, ok := decoder.LinkTarget(def)
+		if !ok {
+			return def
+		}
+		if visited[target] {
+			return ""
+		}
+		visited[target] = true
+		def = m[target]
+	}
+	return ""
 }
 
 func (m Map) Keys() []string {
@@ -14,3 +36,50 @@ func (m Map) Keys() []string {
 
 	return res
 }
+
+// definitions is a value of the JSON dictionaries, a definition, or an array of them for a duplicated key.
+type definitions []string
+
+func (d *definitions) UnmarshalJSON(data []byte) error {
+	var def string
+	if err := json.Unmarshal(data, &def); err == nil {
+		*d = definitions{def}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(d))
+}
+
+// loadMap loads a JSON dictionary, whose values are definitions. The definitions of a duplicated key
+// are joined by new lines, and the redirects among them are followed, so that none of them is hidden.
+func loadMap(data []byte) (Map, error) {
+	var raw map[string]definitions
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	m := make(Map, len(raw))
+	for k, defs := range raw {
+		if len(defs) > 0 {
+			m[k] = defs[0]
+		}
+	}
+	joined := make(map[string]string)
+	for k, defs := range raw {
+		if len(defs) < 2 {
+			continue
+		}
+		var res []string
+		for _, def := range defs {
+			if target, ok := decoder.LinkTarget(def); ok {
+				def = m.Get(target)
+			}
+			if def != "" {
+				res = append(res, def)
+			}
+		}
+		joined[k] = strings.Join(res, "\n")
+	}
+	for k, def := range joined {
+		m[k] = def
+	}
+	return m, nil
+}
@@ -0,0 +1,35 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MapLinks(t *testing.T) {
+	m := Map{
+		"tormenter": "@@@LINK=tormentor",
+		"tormentor": "someone who torments",
+		"cycle":     "@@@LINK=loop",
+		"loop":      "@@@LINK=cycle",
+	}
+	assert.Equal(t, "someone who torments", m.Get("tormenter"))
+	assert.Equal(t, "someone who torments", m.Get("tormentor"))
+	assert.Equal(t, "", m.Get("cycle"))
+	assert.Equal(t, "", m.Get("nothing"))
+}
+
+func Test_loadMap(t *testing.T) {
+	m, err := loadMap([]byte(`{
+		"colour": ["@@@LINK=color", "the British spelling"],
+		"color": "a property of light",
+		"tormentor": "someone who torments"
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "a property of light\nthe British spelling", m.Get("colour"), "the redirect doesn't hide the other definition")
+	assert.Equal(t, "a property of light", m.Get("color"))
+	assert.Equal(t, "someone who torments", m.Get("tormentor"))
+
+	_, err = loadMap([]byte(`{"a": 1}`))
+	assert.NotNil(t, err)
+}
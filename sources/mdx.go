@@ -1,9 +1,9 @@
 package sources
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"os"
 	"strings"
 	"sync"
@@ -18,6 +18,9 @@ import (
 var Gbold = "**"
 var Gitalic = "*"
 
+// KeyLookup decides how the keys of MDX/MDD files are kept in memory.
+var KeyLookup = decoder.LookupKeymap
+
 type Dicts []*MdxDict
 
 var G = &Dicts{}
@@ -28,31 +31,61 @@ func (g *Dicts) Load(fzf bool, mdd bool) error {
 		if err := LoadConfig(); err != nil {
 			log.Fatalf("load config err: %v", err)
 		}
+		loaded := (*g)[:0]
 		for _, d := range *g {
-			d.Register(fzf, mdd)
+			if err := d.Register(fzf, mdd); err != nil {
+				log.Warnf("skip dict %v, register err: %v", d.MdxFile, err)
+				continue
+			}
+			loaded = append(loaded, d)
 		}
+		*g = loaded
 		log.Debugf("loading g")
 	})
 	return nil
 }
 
+// QueryMDX queries all the local dictionaries in G, see Dicts.Query.
 func QueryMDX(word string, f string) string {
+	return G.Query(word, f)
+}
+
+// Query returns the definitions of word in all the dictionaries of g, rendered in format f,
+// which is "html", "json" (see Result), "md" or the plain text.
+func (g *Dicts) Query(word string, f string) string {
+	if f == "json" {
+		return marshalResults(word, g.Results(word))
+	}
 	type mdxResult struct {
-		defs []string
-		css  string
-		t    string // SourceType
+		defs  []string
+		lemma string // the lemma of word which is matched instead, see MdxDict.Match
+		css   string
+		t     string // SourceType
+		name  string
 	}
 	var defs []mdxResult
-	for _, dict := range *G {
-		defs = append(defs, mdxResult{dict.Get(word), dict.CSS(), dict.Type})
+	found := false
+	for _, dict := range *g {
+		matched, lemma := dict.Match(word)
+		found = found || len(matched) > 0
+		var ds []string
+		for _, m := range matched {
+			ds = append(ds, m.GetDefinition())
+		}
+		defs = append(defs, mdxResult{ds, lemma, dict.CSS(), dict.Type, dict.Name()})
 		log.Debugf("def of %q, %v: %q", dict.MdxFile, defs, word)
 	}
 	// TODO: put the render abstraction here?
 	if f == "html" { // f for format
 		var res []string
+		if !found {
+			if dym := didYouMean(g.Suggest(word, 5)); dym != "" {
+				res = append(res, dym)
+			}
+		}
 		for _, dict := range defs {
-			for _, def := range dict.defs {
-				h := render.HTMLRender{Raw: def, SourceType: dict.t}
+			for i, def := range dict.defs {
+				h := render.HTMLRender{Raw: def, SourceType: dict.t, Dict: dict.name}
 				// m1 := regexp.MustCompile(`<img src="(.*?)\.png" style`)
 				// replaceImg := m1.ReplaceAllString(def, `<img src="`+"data/"+`${1}.png" style`)
 				// log.Debugf("try to replace %v", replaceImg)
@@ -61,6 +94,9 @@ func QueryMDX(word string, f string) string {
 				if strings.Contains(dict.t, "Online") {
 					rs = fmt.Sprintf("<script>%s</script>%v", util.CommonJS, rs)
 				}
+				if i == 0 && dict.lemma != "" {
+					rs = fmt.Sprintf(`<div class="ondict-lemma">%s</div>%s`, html.EscapeString(lemmaNote(word, dict.lemma)), rs)
+				}
 				// rs := fmt.Sprintf("%s", h.Render())
 				res = append(res, rs)
 			}
@@ -71,58 +107,70 @@ func QueryMDX(word string, f string) string {
 	log.Debugf("query: %v, format: %v", word, f)
 	var res string
 	for i, dict := range defs {
-		for _, def := range dict.defs {
-			if dict.t == render.LongmanEasy {
-				fd := strings.NewReader(def)
-				res += "\n---\n" + render.ParseMDX(fd, f)
-			} else if dict.t == render.Longman5Online {
-				fd := strings.NewReader(def)
-				res += "\n--\n" + render.ParseHTML(fd)
-			} else {
+		for j, def := range dict.defs {
+			text, ok := renderText(dict.t, def, f)
+			if !ok {
 				log.Debugf("undefined markdown render for %dth dict, whose type is %v", i, dict.t)
+				continue
+			}
+			if j == 0 && dict.lemma != "" {
+				text = Gitalic + lemmaNote(word, dict.lemma) + Gitalic + "\n" + text
+			}
+			if dict.t == render.Longman5Online {
+				res += "\n--\n" + text
+			} else {
+				res += "\n---\n" + text
 			}
 		}
 	}
 	return res
 }
 
-func loadDecodedMdx(filePath string, fzf bool, mdd bool) Dict {
+// mdict adapts *decoder.MDict to Dict, the errors are logged since a broken entry shouldn't break the whole query.
+type mdict struct {
+	*decoder.MDict
+}
+
+func (m mdict) Get(word string) string {
+	def, err := m.MDict.Get(word)
+	if err != nil {
+		log.Warnf("get %q err: %v", word, err)
+	}
+	return def
+}
+
+func (m mdict) GetAll(word string) []string {
+	defs, err := m.MDict.GetAll(word)
+	if err != nil {
+		log.Warnf("get all %q err: %v", word, err)
+	}
+	return defs
+}
+
+func (d *MdxDict) loadDecodedMdx(fzf bool, mdd bool) (Dict, error) {
+	filePath := d.MdxFile
 	jsonData, err := os.ReadFile(filePath + ".json")
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Fatalf("Failed to read JSON file: %v, %v", filePath, err)
+		return nil, fmt.Errorf("read JSON file %v err: %v", filePath, err)
 	} else if errors.Is(err, os.ErrNotExist) {
 		log.Debugf("JSON file not exist: %v", filePath+".json")
-		m := &decoder.MDict{}
-		err := m.Decode(filePath+".mdx", fzf)
-		if !fzf && mdd {
-			go func() {
-				mdd := decoder.MDict{}
-				if err := mdd.Decode(filePath+".mdd", false); err != nil {
-					log.Debugf("[WARN] parse %v.mdd err: %v", filePath, err)
-				} else {
-					log.Debugf("[INFO] successfully decode %v.mdd", filePath)
-					if err := mdd.DumpData(); err != nil {
-						log.Fatalf("dump mdd err: %v", err)
-					}
-				}
-			}()
+		m := &decoder.MDict{UserID: d.UserID, RegCode: d.RegCode, Lookup: KeyLookup, StyleSheet: d.StyleSheet}
+		if err := m.Decode(filePath+".mdx", fzf); err != nil {
+			return nil, fmt.Errorf("load mdx file %v err: %w", filePath, err)
 		}
-		if err != nil {
-			log.Fatalf("Failed to load mdx file[%v], err: %v", filePath, err)
+		if mdd {
+			// preload it in the background, so the server won't be stuck
+			go d.openMdd()
 		}
-		return m
+		return mdict{m}, nil
 	}
 
-	// Define a map to hold the unmarshaled data
-	data := Map(make(map[string]string))
-
-	// Unmarshal the JSON data into the map
-	err = json.Unmarshal(jsonData, &data)
+	data, err := loadMap(jsonData)
 	if err != nil {
-		log.Fatalf("Failed to unmarshal JSON: %v", err)
+		return nil, fmt.Errorf("unmarshal JSON %v err: %v", filePath, err)
 	}
 
-	return data
+	return data, nil
 }
 
 type MdxDict struct {
@@ -131,9 +179,27 @@ type MdxDict struct {
 	// For personal usage example, "oald9.json", or "Longman Dictionary of Contemporary English"
 	MdxFile string
 	// Only match the mdx with the same mdxFile name
-	MdxCss   string
-	MdxDict  Dict
+	MdxCss  string
+	MdxDict Dict
+	// Format is the format of the dictionary files, see DictConfig
+	Format   string
+	mu       sync.RWMutex // owns searcher, fuzzy, keys and fts, which are rebuilt when MdxDict is reloaded
 	searcher Searcher
+	fuzzy    *fuzzyBuild // for the fuzzy suggestions, see fuzzyTree
+	keys     *KeyIndex   // for the completion, see keyIndex
+	fts      *textIndex  // for the full-text search, see textIndex
+	fzf      bool        // whether searcher is an exact one
+	cssFile  string      // where MdxCss is loaded from, empty if it's the concatenation of all the CSS files
+	mddOnce  sync.Once
+	mdd      *decoder.MDict // the resources, opened on demand
+	mddErr   error
+	// For registered MDX files, see DictConfig
+	UserID  string
+	RegCode string
+	// StyleSheet enables the StyleSheet substitution of MDX files, see DictConfig
+	StyleSheet bool
+	// Alias is another name of the dictionary, see DictConfig
+	Alias string
 }
 
 func (d *MdxDict) CSS() string {
@@ -141,23 +207,69 @@ func (d *MdxDict) CSS() string {
 }
 
 func (d *MdxDict) Get(word string) []string {
-	results := d.searcher.GetRawOutputs(strings.ToLower(word))
+	defs := []string{}
+	results, _ := d.Match(word)
+	for _, res := range results {
+		defs = append(defs, res.GetDefinition())
+	}
+	return defs
+}
+
+// Match returns the matched headwords of word and their definitions.
+// If word itself isn't a headword, its lemmas are tried before the inexact matches of the searcher, see Lemmas,
+// and the matched lemma is returned too.
+func (d *MdxDict) Match(word string) ([]RawOutput, string) {
+	d.reload()
+	d.mu.RLock()
+	searcher := d.searcher
+	d.mu.RUnlock()
+	word = strings.ToLower(word)
+	results := searcher.GetRawOutputs(word)
+	if exact := matchesOf(results, word); len(exact) > 0 {
+		return exact, ""
+	}
+	for _, lemma := range Lemmas(word) {
+		// only the keys are checked, the records are read for the lemma found
+		if exact := d.records(d.keyIndex().Lookup(lemma)); len(exact) > 0 {
+			log.Debugf("%q is matched by its lemma %q in %v", word, lemma, d.MdxFile)
+			return exact, lemma
+		}
+	}
 	if len(results) == 0 {
-		return []string{}
+		return []RawOutput{}, ""
 	}
-	// TODO: Give user the options.
-	// Naive solution: Give user the longest match.
+	// Naive solution: Give user the longest match, the others are the suggestions, see Suggest.
 	// What about same length? Show all of them.
-	var maxes, defs []string
+	var maxes []RawOutput
 	for _, res := range results {
 		m := res.GetMatch()
-		if len(maxes) == 0 || len(m) > len(maxes[0]) {
-			maxes = []string{m}
-			defs = []string{res.GetDefinition()}
-		} else if len(m) == len(maxes[0]) {
-			maxes = append(maxes, m)
-			defs = append(defs, res.GetDefinition())
+		if len(maxes) == 0 || len(m) > len(maxes[0].GetMatch()) {
+			maxes = []RawOutput{res}
+		} else if len(m) == len(maxes[0].GetMatch()) {
+			maxes = append(maxes, res)
 		}
 	}
-	return defs
+	return maxes, ""
+}
+
+// matchesOf returns the results whose headwords are word, case-insensitively.
+func matchesOf(results []RawOutput, word string) []RawOutput {
+	var res []RawOutput
+	for _, r := range results {
+		if strings.EqualFold(r.GetMatch(), word) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// records returns the definitions of keys.
+func (d *MdxDict) records(keys []string) []RawOutput {
+	var res []RawOutput
+	for _, k := range keys {
+		for _, def := range getAll(d.MdxDict, k) {
+			res = append(res, output{rawWord: k, def: def})
+		}
+	}
+	return res
 }
@@ -29,9 +29,21 @@ type Searcher interface {
 	GetRawOutputs(string) []RawOutput
 }
 
-type Source interface {
-	Register() error
-	Get(string) []string
+// multiGetter is implemented by dictionaries which may have more than one definition for a key,
+// such as *decoder.MDict.
+type multiGetter interface {
+	GetAll(string) []string
+}
+
+// getAll returns all the (non-empty) definitions of word in dict.
+func getAll(dict Dict, word string) []string {
+	if m, ok := dict.(multiGetter); ok {
+		return m.GetAll(word)
+	}
+	if def := dict.Get(word); def != "" {
+		return []string{def}
+	}
+	return nil
 }
 
 type output struct {
@@ -68,8 +80,13 @@ func loadAllCss() (string, error) {
 }
 
 func (d *MdxDict) Register(fzf bool, mdd bool) error {
-	d.MdxDict = loadDecodedMdx(d.MdxFile, fzf, mdd)
+	dict, err := d.load(fzf, mdd)
+	if err != nil {
+		return err
+	}
+	d.MdxDict = dict
 	if contents, err := os.ReadFile(d.MdxCss); err == nil {
+		d.cssFile = d.MdxCss
 		d.MdxCss = string(contents)
 	} else {
 		if css, err := loadAllCss(); err != nil {
@@ -78,10 +95,41 @@ func (d *MdxDict) Register(fzf bool, mdd bool) error {
 			d.MdxCss = string(css)
 		}
 	}
-	if !fzf {
-		d.searcher = NewAho(d.MdxDict)
-	} else {
-		d.searcher = NewExact(d.MdxDict)
-	}
+	d.fzf = fzf
+	d.searcher, d.fuzzy, d.keys, d.fts = d.newSearcher(), nil, nil, nil
 	return nil
 }
+
+func (d *MdxDict) newSearcher() Searcher {
+	if FuzzyDistance > 0 {
+		return NewBKTree(d.MdxDict, FuzzyDistance)
+	}
+	if !d.fzf {
+		return NewAho(d.MdxDict)
+	}
+	return NewExact(d.MdxDict)
+}
+
+// reloader is implemented by dictionaries which can be modified after they are loaded, such as *Glossary.
+type reloader interface {
+	Reload() (bool, error)
+}
+
+// reload reloads the dictionary if it's modified, and rebuilds the searcher.
+func (d *MdxDict) reload() {
+	r, ok := d.MdxDict.(reloader)
+	if !ok {
+		return
+	}
+	changed, err := r.Reload()
+	if err != nil {
+		log.Warnf("reload %v err: %v", d.MdxFile, err)
+		return
+	}
+	if changed {
+		searcher := d.newSearcher()
+		d.mu.Lock()
+		d.searcher, d.fuzzy, d.keys, d.fts = searcher, nil, nil, nil
+		d.mu.Unlock()
+	}
+}
@@ -1,6 +1,8 @@
 package sources
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,3 +13,10 @@ func Test_loadCssFiles(t *testing.T) {
 	assert.Nil(t, err)
 	t.Logf("css file concatenation: \n%v", res)
 }
+
+func Test_RegisterBrokenMdx(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "broken")
+	assert.Nil(t, os.WriteFile(name+".mdx", []byte("not an mdx file"), 0o644))
+	d := &MdxDict{MdxFile: name}
+	assert.NotNil(t, d.Register(true, false))
+}
@@ -0,0 +1,252 @@
+package sources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/render"
+)
+
+// Source is a query engine, such as "online" for the Longman online dictionary,
+// and "mdx" for the local dictionaries.
+type Source interface {
+	// Register prepares the source, it's called once before the first Lookup.
+	Register() error
+	// Lookup returns the definitions of word rendered in format f, i.e. "html", "md" or the plain text,
+	// it's empty if there is none.
+	Lookup(word string, f string) string
+	// Keys returns all the words in the source, it's nil if they can't be listed, e.g. for the online ones.
+	Keys() []string
+}
+
+type registered struct {
+	source Source
+	once   sync.Once
+	err    error
+}
+
+var registryMu sync.RWMutex // owns registry
+var registry = make(map[string]*registered)
+
+// DefaultEngines are the sources used when no engine is specified,
+// it's overridden by "engines" in config.json.
+var DefaultEngines = []string{"online"}
+
+func init() {
+	Register("online", Online{})
+	RegisterLocal(true, false)
+}
+
+// RegisterLocal registers the sources of the local dictionaries in G, which are loaded with fzf and mdd, see Dicts.Load.
+// It replaces the ones registered before, so it should be called before they are looked up.
+func RegisterLocal(fzf bool, mdd bool) {
+	// "mdx" stands for all the local dictionaries, as it did before the other formats were supported
+	Register("mdx", &Local{Dicts: G, Fzf: fzf, MDD: mdd})
+	for _, format := range []string{"stardict", "dsl", render.Glossary} {
+		Register(format, &Local{Dicts: G, Format: format, Fzf: fzf, MDD: mdd})
+	}
+}
+
+// Register makes s available by name, it replaces the source of the same name registered before.
+func Register(name string, s Source) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = &registered{source: s}
+}
+
+// Lookup returns the source registered by name, which is registered (by Source.Register) if it's not yet.
+func Lookup(name string) (Source, error) {
+	registryMu.RLock()
+	r, ok := registry[strings.ToLower(strings.TrimSpace(name))]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %q, the engines are %v", name, Names())
+	}
+	r.once.Do(func() {
+		r.err = r.source.Register()
+	})
+	if r.err != nil {
+		return nil, fmt.Errorf("register engine %q err: %v", name, r.err)
+	}
+	return r.source, nil
+}
+
+// Names returns the names of all the registered sources.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	res := make([]string, 0, len(registry))
+	for name := range registry {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Engines splits a comma-separated list of engines, such as "glossary,mdx",
+// DefaultEngines are returned if it's empty.
+func Engines(engines string) []string {
+	var res []string
+	for _, e := range strings.Split(engines, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			res = append(res, e)
+		}
+	}
+	if len(res) == 0 {
+		return DefaultEngines
+	}
+	return res
+}
+
+// Query looks word up in the sources named by engines in order, see Engines, and joins the results.
+// The unknown engines are skipped, DefaultEngines are used if none of them is known.
+// Only the dictionaries named dicts are queried if it's not empty, see DictNames and Dicts.Select,
+// it doesn't affect the sources without dictionaries, such as "online".
+// The structured results are returned in JSON if f is "json", see Results.
+func Query(engines string, dicts string, word string, f string) string {
+	if f == "json" {
+		return marshalResults(word, Results(engines, dicts, word))
+	}
+	sep := "\n"
+	if f == "html" {
+		sep = "<br><br>"
+	}
+	var res []string
+	for _, e := range lookupEngines(engines, dicts) {
+		if r := e.source.Lookup(word, f); strings.TrimSpace(r) != "" {
+			res = append(res, r)
+		}
+	}
+	return strings.Join(res, sep)
+}
+
+type engine struct {
+	name   string
+	source Source
+}
+
+// lookupEngines returns the sources named by engines, narrowed down to the dictionaries named dicts, see Query.
+func lookupEngines(engines string, dicts string) []engine {
+	var res []engine
+	for _, e := range Engines(engines) {
+		s, err := Lookup(e)
+		if err != nil {
+			log.Warnf("skip engine: %v", err)
+			continue
+		}
+		if sel, ok := s.(selector); ok && dicts != "" {
+			s = sel.Select(DictNames(dicts))
+		}
+		res = append(res, engine{strings.ToLower(e), s})
+	}
+	if len(res) == 0 && engines != "" {
+		return lookupEngines("", dicts)
+	}
+	return res
+}
+
+// Online is the source of the Longman online dictionary.
+type Online struct{}
+
+func (Online) Register() error {
+	return nil
+}
+
+func (Online) Lookup(word string, f string) string {
+	return GetFromLDOCE(word)
+}
+
+func (Online) Keys() []string {
+	return nil
+}
+
+// Local is the source of the local dictionaries in Dicts, which are loaded by Dicts.Load.
+// Only the dictionaries of Format are queried if it's set, see MdxDict.format.
+type Local struct {
+	Dicts  *Dicts
+	Format string
+	// Fzf and MDD are the arguments of Dicts.Load when the dictionaries are loaded by Register.
+	Fzf bool
+	MDD bool
+}
+
+// Register loads the dictionaries if they are not loaded yet.
+func (l *Local) Register() error {
+	return l.Dicts.Load(l.Fzf, l.MDD)
+}
+
+func (l *Local) dicts() *Dicts {
+	if l.Format == "" {
+		return l.Dicts
+	}
+	var res Dicts
+	for _, d := range *l.Dicts {
+		if d.format() == l.Format {
+			res = append(res, d)
+		}
+	}
+	return &res
+}
+
+func (l *Local) Lookup(word string, f string) string {
+	return l.dicts().Query(word, f)
+}
+
+func (l *Local) Keys() []string {
+	var res []string
+	for _, d := range *l.dicts() {
+		res = append(res, d.MdxDict.Keys()...)
+	}
+	return res
+}
+
+// Loader loads the files of a local dictionary d, fzf and mdd are the arguments of Dicts.Load.
+type Loader func(d *MdxDict, fzf bool, mdd bool) (Dict, error)
+
+var formatsMu sync.RWMutex // owns formats
+var formats = map[string]Loader{
+	"mdx": (*MdxDict).loadDecodedMdx,
+	"stardict": func(d *MdxDict, _, _ bool) (Dict, error) {
+		return d.loadStarDict()
+	},
+	"dsl": func(d *MdxDict, _, _ bool) (Dict, error) {
+		return d.loadDSL()
+	},
+	render.Glossary: func(d *MdxDict, _, _ bool) (Dict, error) {
+		return d.loadGlossary()
+	},
+}
+
+// RegisterFormat makes the local dictionaries of format loadable by l, the format is chosen by
+// "format" in config.json, see DictConfig. The dictionaries can be queried by a Local source.
+func RegisterFormat(format string, l Loader) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[strings.ToLower(format)] = l
+}
+
+// format returns the format of d, which is "glossary" for the glossaries, "mdx" by default.
+func (d *MdxDict) format() string {
+	if d.Type == render.Glossary {
+		return render.Glossary
+	}
+	if d.Format == "" {
+		return "mdx"
+	}
+	return strings.ToLower(d.Format)
+}
+
+// load loads the files of d with the Loader of its format.
+func (d *MdxDict) load(fzf bool, mdd bool) (Dict, error) {
+	formatsMu.RLock()
+	l, ok := formats[d.format()]
+	formatsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q of dict %v", d.Format, d.MdxFile)
+	}
+	return l(d, fzf, mdd)
+}
@@ -0,0 +1,100 @@
+package sources
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	defs       map[string]string
+	registered int
+	err        error
+}
+
+func (s *fakeSource) Register() error {
+	s.registered++
+	return s.err
+}
+
+func (s *fakeSource) Lookup(word string, f string) string {
+	return s.defs[word]
+}
+
+func (s *fakeSource) Keys() []string {
+	return Map(s.defs).Keys()
+}
+
+func Test_Query(t *testing.T) {
+	a := &fakeSource{defs: map[string]string{"go": "a-go", "only": "a-only"}}
+	b := &fakeSource{defs: map[string]string{"go": "b-go"}}
+	broken := &fakeSource{defs: map[string]string{"go": "broken"}, err: errors.New("broken")}
+	Register("test-a", a)
+	Register("Test-B", b)
+	Register("test-broken", broken)
+	old := DefaultEngines
+	defer func() { DefaultEngines = old }()
+	DefaultEngines = []string{"test-b"}
+
+	assert.Equal(t, "a-go\nb-go", Query("test-a, test-b", "", "go", "md"))
+	assert.Equal(t, "b-go<br><br>a-go", Query("test-b,test-a", "", "go", "html"))
+	assert.Equal(t, "a-only", Query("test-b,test-a", "", "only", "md"))
+	assert.Equal(t, "b-go", Query("", "", "go", "md"))
+	assert.Equal(t, "a-go", Query("nothing,test-a,test-broken", "", "go", "md"))
+	assert.Equal(t, "b-go", Query("nothing,test-broken", "", "go", "md"), "the default engines are used if none is available")
+	assert.Equal(t, 1, a.registered)
+	assert.Equal(t, 1, b.registered)
+	assert.Equal(t, 1, broken.registered)
+
+	_, err := Lookup("nothing")
+	assert.NotNil(t, err)
+	s, err := Lookup(" TEST-A")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"go", "only"}, s.Keys())
+	assert.Subset(t, Names(), []string{"online", "mdx", "stardict", "dsl", "glossary", "test-a", "test-b"})
+}
+
+func Test_LocalFormats(t *testing.T) {
+	RegisterFormat("Upper", func(d *MdxDict, _, _ bool) (Dict, error) {
+		data, err := os.ReadFile(d.MdxFile + ".txt")
+		if err != nil {
+			return nil, err
+		}
+		k, v, _ := strings.Cut(strings.TrimSpace(string(data)), "=")
+		return Map{k: strings.ToUpper(v)}, nil
+	})
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "upper.txt"), []byte("go=to move\n"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "team.tsv"), []byte("go\ta language\n"), 0o644))
+	upper := &MdxDict{MdxFile: filepath.Join(dir, "upper"), Format: "upper"}
+	assert.Nil(t, upper.Register(true, false))
+	team := &MdxDict{MdxFile: filepath.Join(dir, "team"), Type: "glossary"}
+	assert.Nil(t, team.Register(true, false))
+	g := &Dicts{upper, team}
+
+	all := &Local{Dicts: g}
+	assert.Equal(t, "\n---\na language", all.Lookup("go", "md"))
+	assert.Contains(t, all.Lookup("go", "html"), "TO MOVE")
+	assert.ElementsMatch(t, []string{"go", "go"}, all.Keys())
+	glossary := &Local{Dicts: g, Format: "glossary"}
+	assert.NotContains(t, glossary.Lookup("go", "html"), "TO MOVE")
+	assert.Contains(t, (&Local{Dicts: g, Format: "upper"}).Lookup("go", "html"), "TO MOVE")
+
+	assert.NotNil(t, (&MdxDict{MdxFile: filepath.Join(dir, "upper"), Format: "lower"}).Register(true, false))
+}
+
+func Test_RegisterLocal(t *testing.T) {
+	defer RegisterLocal(true, false)
+	RegisterLocal(false, true)
+	for _, name := range []string{"mdx", "glossary"} {
+		registryMu.RLock()
+		l := registry[name].source.(*Local)
+		registryMu.RUnlock()
+		assert.False(t, l.Fzf, name)
+		assert.True(t, l.MDD, name)
+	}
+}
@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+// openMdd opens the MDD file next to the MDX/JSON file of d, only once.
+func (d *MdxDict) openMdd() (*decoder.MDict, error) {
+	d.mddOnce.Do(func() {
+		name := d.MdxFile + ".mdd"
+		if _, err := os.Stat(name); err != nil {
+			d.mddErr = fmt.Errorf("%w: %v", decoder.ErrNotFound, err)
+			return
+		}
+		mdd := &decoder.MDict{UserID: d.UserID, RegCode: d.RegCode, Lookup: KeyLookup}
+		if err := mdd.Decode(name, true); err != nil {
+			log.Warnf("parse %v err: %v", name, err)
+			d.mddErr = err
+			return
+		}
+		log.Debugf("successfully decode %v", name)
+		d.mdd = mdd
+	})
+	return d.mdd, d.mddErr
+}
+
+// Resource returns the content of path in the MDD file of d, see decoder.ResourceKey for the path.
+// The resources of StarDict dictionaries are in the "res" directory next to their files.
+func (d *MdxDict) Resource(path string) ([]byte, error) {
+	if strings.EqualFold(d.Format, "stardict") {
+		return d.starDictResource(path)
+	}
+	mdd, err := d.openMdd()
+	if err != nil {
+		return nil, err
+	}
+	return mdd.Resource(path)
+}
+
+// Resource returns the content of path in the MDD file of the dictionary named name, see DictInfo.Name.
+// All the dictionaries are searched in order if name is empty.
+func (g *Dicts) Resource(name string, path string) ([]byte, error) {
+	for _, d := range *g {
+		if name != "" && d.Name() != name {
+			continue
+		}
+		data, err := d.Resource(path)
+		if err == nil || name != "" {
+			return data, err
+		}
+		if !errors.Is(err, decoder.ErrNotFound) {
+			log.Debugf("get resource %q from %v err: %v", path, d.MdxFile, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %v in %q", decoder.ErrNotFound, path, name)
+}
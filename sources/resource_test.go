@@ -0,0 +1,70 @@
+package sources
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+)
+
+// writeDict writes name.mdx and name.mdd in dir, with the definitions and the resource files respectively.
+func writeDict(t *testing.T, dir string, name string, defs map[string]string, files map[string][]byte) *MdxDict {
+	t.Helper()
+	e := decoder.Encoder{Header: decoder.Header{Title: name}}
+	var mdx bytes.Buffer
+	assert.Nil(t, e.WriteMDX(&mdx, defs))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, name+".mdx"), mdx.Bytes(), 0o644))
+	if files != nil {
+		res := t.TempDir()
+		for path, data := range files {
+			path = filepath.Join(res, filepath.FromSlash(path))
+			assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			assert.Nil(t, os.WriteFile(path, data, 0o644))
+		}
+		var mdd bytes.Buffer
+		assert.Nil(t, e.WriteMDD(&mdd, res))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name+".mdd"), mdd.Bytes(), 0o644))
+	}
+	d := &MdxDict{MdxFile: filepath.Join(dir, name)}
+	assert.Nil(t, d.Register(true, false))
+	return d
+}
+
+func Test_DictsResource(t *testing.T) {
+	dir := t.TempDir()
+	ldoce := writeDict(t, dir, "ldoce", map[string]string{"doctor": "a doctor"}, map[string][]byte{
+		"US_doctor1.mp3": []byte("ldoce doctor"),
+		"img/x.png":      []byte("ldoce x"),
+	})
+	oald := writeDict(t, dir, "oald", map[string]string{"doctor": "another doctor"}, map[string][]byte{
+		"US_doctor1.mp3": []byte("oald doctor"),
+		"only.css":       []byte("oald css"),
+	})
+	plain := writeDict(t, dir, "plain", map[string]string{"doctor": "no resources"}, nil)
+	g := &Dicts{plain, ldoce, oald}
+
+	data, err := g.Resource("oald", "us_doctor1.mp3")
+	assert.Nil(t, err)
+	assert.Equal(t, "oald doctor", string(data))
+	data, err = g.Resource("ldoce", `\img\X.png`)
+	assert.Nil(t, err)
+	assert.Equal(t, "ldoce x", string(data))
+	_, err = g.Resource("ldoce", "only.css")
+	assert.ErrorIs(t, err, decoder.ErrNotFound)
+	_, err = g.Resource("plain", "only.css")
+	assert.ErrorIs(t, err, decoder.ErrNotFound)
+	_, err = g.Resource("nothing", "only.css")
+	assert.ErrorIs(t, err, decoder.ErrNotFound)
+
+	// the first one is used for a flat path
+	data, err = g.Resource("", "/US_doctor1.mp3")
+	assert.Nil(t, err)
+	assert.Equal(t, "ldoce doctor", string(data))
+	data, err = g.Resource("", "/only.css")
+	assert.Nil(t, err)
+	assert.Equal(t, "oald css", string(data))
+}
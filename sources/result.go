@@ -0,0 +1,110 @@
+package sources
+
+import (
+	"encoding/json"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/dsl"
+	"github.com/ChaosNyaruko/ondict/render"
+)
+
+// Result is a definition of a word in a dictionary, it's what "-f json" and "format=json" output,
+// so that the clients can build their own UIs.
+type Result struct {
+	// Engine is the source the result comes from, such as "online" or "mdx".
+	Engine string `json:"engine"`
+	// Dict is the name of the dictionary, it's empty for the sources without dictionaries.
+	Dict  string `json:"dict,omitempty"`
+	Alias string `json:"alias,omitempty"`
+	// Type is the SourceType of the dictionary, see DictConfig.
+	Type string `json:"type,omitempty"`
+	// Word is the matched headword.
+	Word string `json:"word"`
+	// Lemma is the lemma of the queried word which is matched instead of it, e.g. "go" for "went", see Lemmas.
+	Lemma string `json:"lemma,omitempty"`
+	// Definition is the raw definition in the dictionary.
+	Definition string `json:"definition,omitempty"`
+	CSS        string `json:"css,omitempty"`
+	// HTML and Markdown are the rendered definition, Markdown is empty if there is no markdown renderer for Type.
+	HTML     string `json:"html,omitempty"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// resulter is implemented by sources which can return the structured results, such as *Local,
+// the results of the others are their HTML outputs.
+type resulter interface {
+	Results(word string) []Result
+}
+
+// Results looks word up in the sources named by engines in order, like Query, but returns the structured results.
+func Results(engines string, dicts string, word string) []Result {
+	res := []Result{}
+	for _, e := range lookupEngines(engines, dicts) {
+		if r, ok := e.source.(resulter); ok {
+			for _, x := range r.Results(word) {
+				x.Engine = e.name
+				res = append(res, x)
+			}
+		} else if h := e.source.Lookup(word, "html"); strings.TrimSpace(h) != "" {
+			res = append(res, Result{Engine: e.name, Word: word, HTML: h})
+		}
+	}
+	return res
+}
+
+// marshalResults returns the JSON of the results of word.
+func marshalResults(word string, res []Result) string {
+	data, err := json.Marshal(res)
+	if err != nil {
+		log.Warnf("marshal results of %q err: %v", word, err)
+		return "[]"
+	}
+	return string(data)
+}
+
+func (l *Local) Results(word string) []Result {
+	return l.dicts().Results(word)
+}
+
+// Results returns the definitions of word in all the dictionaries of g.
+func (g *Dicts) Results(word string) []Result {
+	res := []Result{}
+	for _, dict := range *g {
+		matched, lemma := dict.Match(word)
+		for _, m := range matched {
+			def := m.GetDefinition()
+			h := render.HTMLRender{Raw: def, SourceType: dict.Type, Dict: dict.Name()}
+			md, _ := renderText(dict.Type, def, "md")
+			res = append(res, Result{
+				Dict:       dict.Name(),
+				Alias:      dict.Alias,
+				Type:       dict.Type,
+				Word:       m.GetMatch(),
+				Lemma:      lemma,
+				Definition: def,
+				CSS:        dict.CSS(),
+				HTML:       h.Render(),
+				Markdown:   md,
+			})
+		}
+	}
+	return res
+}
+
+// renderText renders def of a dictionary of SourceType t in format f, which is "md" or the plain text,
+// it reports false if there is no such renderer for t.
+func renderText(t string, def string, f string) (string, bool) {
+	switch t {
+	case render.LongmanEasy:
+		return render.ParseMDX(strings.NewReader(def), f), true
+	case render.Longman5Online:
+		return render.ParseHTML(strings.NewReader(def)), true
+	case render.DSL:
+		return dsl.ToMarkdown(def), true
+	case render.Glossary:
+		return def, true
+	}
+	return "", false
+}
@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loadedLocal is a Local source whose dictionaries are loaded already.
+type loadedLocal struct {
+	*Local
+}
+
+func (loadedLocal) Register() error {
+	return nil
+}
+
+func Test_Results(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "team")
+	assert.Nil(t, os.WriteFile(base+".tsv", []byte("SLO\t**Service** Level Objective\n"), 0o644))
+	assert.Nil(t, os.WriteFile(base+".css", []byte("p { color: red; }"), 0o644))
+	d := &MdxDict{MdxFile: base, Type: "glossary", Alias: "tm", MdxCss: base + ".css"}
+	assert.Nil(t, d.Register(true, false))
+	g := &Dicts{d}
+
+	assert.Equal(t, []Result{{
+		Dict:       "team",
+		Alias:      "tm",
+		Type:       "glossary",
+		Word:       "slo",
+		Definition: "**Service** Level Objective",
+		CSS:        "p { color: red; }",
+		HTML:       "<p><b>Service</b> Level Objective</p>\n",
+		Markdown:   "**Service** Level Objective",
+	}}, g.Results("SLO"))
+	assert.Equal(t, []Result{}, g.Results("SLA"))
+	assert.Equal(t, "[]", g.Query("SLA", "json"))
+
+	Register("test-local", loadedLocal{&Local{Dicts: g}})
+	Register("test-online", &fakeSource{defs: map[string]string{"slo": "<b>slo</b>"}})
+	res := Results("test-online,test-local", "", "slo")
+	assert.Len(t, res, 2)
+	assert.Equal(t, Result{Engine: "test-online", Word: "slo", HTML: "<b>slo</b>"}, res[0])
+	assert.Equal(t, "test-local", res[1].Engine)
+	assert.Equal(t, "team", res[1].Dict)
+	assert.Equal(t, []Result{}, Results("test-local", "nothing", "slo"), "no dictionary is selected")
+
+	var decoded []Result
+	assert.Nil(t, json.Unmarshal([]byte(Query("test-online,test-local", "", "slo", "json")), &decoded))
+	assert.Equal(t, res, decoded)
+}
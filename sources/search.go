@@ -0,0 +1,263 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ChaosNyaruko/ondict/dsl"
+	"github.com/ChaosNyaruko/ondict/fulltext"
+	"github.com/ChaosNyaruko/ondict/render"
+	"github.com/ChaosNyaruko/ondict/util"
+)
+
+// FullText enables building the full-text indexes of the dictionaries in the background when the server starts,
+// otherwise the index of a dictionary is built when it's searched the first time, see Dicts.Search.
+var FullText = false
+
+// indexBatch is the number of the entries indexed at a time, the searches wait for at most a batch.
+const indexBatch = 5000
+
+// saveInterval is how often the index being built is saved, besides when it's done.
+var saveInterval = time.Minute
+
+// textIndex is the full-text index of a dictionary, which is loaded and built in the background.
+type textIndex struct {
+	file string
+
+	mu    sync.RWMutex    // owns keys and index
+	keys  []string        // the documents of index, the sorted keys when it's started
+	index *fulltext.Index // nil until it's loaded
+}
+
+// Hit is an entry found by the full-text search.
+type Hit struct {
+	Dict string `json:"dict"`
+	Word string `json:"word"`
+	// Snippet is the text around the phrase in the definition.
+	Snippet string `json:"snippet"`
+}
+
+// IndexProgress is the progress of building the full-text index of a dictionary.
+type IndexProgress struct {
+	Dict    string `json:"dict"`
+	Indexed int    `json:"indexed"`
+	Total   int    `json:"total"`
+	// Loading is true until the saved index is loaded, when Indexed and Total are unknown.
+	Loading bool `json:"loading,omitempty"`
+}
+
+// Done reports whether the index is fully built.
+func (p IndexProgress) Done() bool {
+	return !p.Loading && p.Indexed >= p.Total
+}
+
+func (p IndexProgress) String() string {
+	if p.Loading {
+		return fmt.Sprintf("the index of %s is being loaded, the results may be incomplete.", p.Dict)
+	}
+	return fmt.Sprintf("%s is being indexed (%d/%d), the results may be incomplete.", p.Dict, p.Indexed, p.Total)
+}
+
+// indexFile is where the full-text index of d is saved, named by the hash of the path as well,
+// since the dictionaries in different directories may have the same name.
+func (d *MdxDict) indexFile() string {
+	h := fnv.New32a()
+	h.Write([]byte(d.MdxFile))
+	return filepath.Join(util.TmpDir(), fmt.Sprintf("%s-%08x.fulltext", d.Name(), h.Sum32()))
+}
+
+// writeStamp writes the names, sizes and modification times of the files of d, i.e. "<MdxFile>.*", to w,
+// which change when the dictionary is edited, e.g. the definitions of a glossary.
+func (d *MdxDict) writeStamp(w io.Writer) {
+	dir, base := filepath.Split(d.MdxFile)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Debugf("read the files of %v err: %v", d.MdxFile, err)
+		return
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), base+".") || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s\x00%d\x00%d\x00", e.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+}
+
+// textIndex returns the full-text index of d, which is loaded from the file, and continued to be built in the background.
+func (d *MdxDict) textIndex() *textIndex {
+	d.reload()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fts == nil {
+		d.fts = &textIndex{file: d.indexFile()}
+		go d.buildIndex(d.fts)
+	}
+	return d.fts
+}
+
+// loadIndex loads the saved index of t, or a new one if it's stale, i.e. the keys or the files of d are changed.
+func (d *MdxDict) loadIndex(t *textIndex) {
+	keys := NewKeyIndex(d.MdxDict.Keys()).words
+	h := fnv.New64a()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+	}
+	d.writeStamp(h)
+	signature := fmt.Sprintf("%d-%x", len(keys), h.Sum64())
+	x, err := fulltext.Load(t.file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("load full-text index of %v err: %v, it's rebuilt", d.MdxFile, err)
+	}
+	if x == nil || x.Signature != signature {
+		x = fulltext.New(signature)
+	}
+	t.mu.Lock()
+	t.keys, t.index = keys, x
+	t.mu.Unlock()
+}
+
+// buildIndex loads the index of t and indexes the rest of the entries, the index is saved every saveInterval and when it's done.
+func (d *MdxDict) buildIndex(t *textIndex) {
+	d.loadIndex(t)
+	t.mu.RLock()
+	start, total := t.index.Done, len(t.keys)
+	t.mu.RUnlock()
+	if start >= total {
+		return
+	}
+	log.Infof("build the full-text index of %v from %d/%d", d.MdxFile, start, total)
+	save := func() {
+		t.mu.RLock()
+		err := t.index.Save(t.file)
+		t.mu.RUnlock()
+		if err != nil {
+			log.Warnf("save full-text index of %v err: %v", d.MdxFile, err)
+		}
+	}
+	saved := time.Now()
+	for start < total {
+		d.mu.RLock()
+		stale := d.fts != t
+		d.mu.RUnlock()
+		if stale {
+			// the dictionary is reloaded, and a new index is being built
+			return
+		}
+		end := start + indexBatch
+		if end > total {
+			end = total
+		}
+		texts := make([]string, 0, end-start)
+		for _, k := range t.keys[start:end] {
+			texts = append(texts, d.text(k))
+		}
+		t.mu.Lock()
+		for i, text := range texts {
+			t.index.Add(uint32(start+i), text)
+		}
+		t.index.Done = end
+		t.mu.Unlock()
+		if time.Since(saved) >= saveInterval {
+			save()
+			saved = time.Now()
+		}
+		start = end
+	}
+	save()
+	log.Infof("the full-text index of %v is built", d.MdxFile)
+}
+
+// text returns the plain text of the definitions of key.
+func (d *MdxDict) text(key string) string {
+	var texts []string
+	for _, def := range getAll(d.MdxDict, key) {
+		if d.Type == render.DSL {
+			def = dsl.ToHTML(def)
+		}
+		texts = append(texts, fulltext.Text(def))
+	}
+	return strings.Join(texts, " ")
+}
+
+// Search returns at most limit entries of d whose definitions contain phrase, see fulltext.Find.
+// Only the indexed entries are searched if the index isn't fully built yet, see Progress.
+// The entries of the same definitions, usually the redirects like "arteries" to "artery", are found once by the shortest key.
+func (d *MdxDict) Search(phrase string, limit int) []Hit {
+	t := d.textIndex()
+	t.mu.RLock()
+	var candidates []uint32
+	if t.index != nil {
+		candidates = t.index.Candidates(phrase)
+	}
+	keys := t.keys
+	t.mu.RUnlock()
+	var res []Hit
+	found := make(map[string]int) // text -> index in res
+	for _, doc := range candidates {
+		key := keys[doc]
+		text := d.text(key)
+		if i, ok := found[text]; ok {
+			if len(key) < len(res[i].Word) {
+				res[i].Word = key
+			}
+			continue
+		}
+		if len(res) >= limit {
+			break
+		}
+		if start, end, ok := fulltext.Find(text, phrase); ok {
+			found[text] = len(res)
+			res = append(res, Hit{Dict: d.Name(), Word: key, Snippet: fulltext.Snippet(text, start, end, 60)})
+		}
+	}
+	return res
+}
+
+// Progress returns the progress of building the full-text index of d.
+func (d *MdxDict) Progress() IndexProgress {
+	t := d.textIndex()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.index == nil {
+		return IndexProgress{Dict: d.Name(), Loading: true}
+	}
+	return IndexProgress{Dict: d.Name(), Indexed: t.index.Done, Total: len(t.keys)}
+}
+
+// BuildIndexes starts building the full-text indexes of the dictionaries of g in the background.
+func (g *Dicts) BuildIndexes() {
+	for _, d := range *g {
+		d.textIndex()
+	}
+}
+
+// Search returns at most limit entries of the dictionaries of g whose definitions contain phrase,
+// and the progress of the indexes which are not fully built yet, see MdxDict.Search.
+func (g *Dicts) Search(phrase string, limit int) ([]Hit, []IndexProgress) {
+	hits := []Hit{}
+	var building []IndexProgress
+	for _, d := range *g {
+		hits = append(hits, d.Search(phrase, limit-len(hits))...)
+		if p := d.Progress(); !p.Done() {
+			building = append(building, p)
+		}
+		if len(hits) >= limit {
+			break
+		}
+	}
+	return hits, building
+}
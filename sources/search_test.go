@@ -0,0 +1,85 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Search(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	words := Map{
+		"artery":   `<div>a <b>blood</b> vessel</div><style>.blood{}</style>`,
+		"vein":     "a blood-vessel carrying blood to the heart",
+		"ship":     "a large vessel",
+		"arteries": "@@@LINK=artery",
+	}
+	newDict := func() *MdxDict {
+		d := &MdxDict{MdxFile: "/dicts/a", MdxDict: words}
+		d.searcher = d.newSearcher()
+		return d
+	}
+	d := newDict()
+	g := &Dicts{d}
+	g.BuildIndexes()
+	assert.Eventually(t, func() bool { return d.Progress().Indexed == 4 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, IndexProgress{Dict: "a", Indexed: 4, Total: 4}, d.Progress())
+
+	hits, building := g.Search("Blood Vessel", 10)
+	assert.Nil(t, building)
+	assert.Equal(t, []Hit{
+		{Dict: "a", Word: "artery", Snippet: "a blood vessel"},
+		{Dict: "a", Word: "vein", Snippet: "a blood-vessel carrying blood to the heart"},
+	}, hits)
+	hits, _ = g.Search("blood vessel", 1)
+	assert.Len(t, hits, 1)
+	hits, _ = g.Search("vessel blood", 10)
+	assert.Empty(t, hits)
+
+	// the saved index is loaded, and it's rebuilt if the keys are changed
+	_, err := os.Stat(d.indexFile())
+	assert.Nil(t, err)
+	d = newDict()
+	assert.Eventually(t, func() bool { return d.Progress().Done() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, IndexProgress{Dict: "a", Indexed: 4, Total: 4}, d.Progress())
+	more := Map{"boat": "a small vessel"}
+	for k, v := range words {
+		more[k] = v
+	}
+	d = &MdxDict{MdxFile: "/dicts/a", MdxDict: more}
+	d.searcher = d.newSearcher()
+	assert.Eventually(t, func() bool { return d.Progress().Indexed == 5 }, time.Second, 10*time.Millisecond)
+	hits = d.Search("vessel", 10)
+	assert.Equal(t, []string{"artery", "boat", "ship", "vein"}, []string{hits[0].Word, hits[1].Word, hits[2].Word, hits[3].Word})
+}
+
+func Test_SearchEdited(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	file := filepath.Join(dir, "team.tsv")
+	newDict := func() *MdxDict {
+		d := &MdxDict{MdxFile: filepath.Join(dir, "team"), Type: "glossary"}
+		assert.Nil(t, d.Register(true, false))
+		assert.Eventually(t, func() bool { return d.Progress().Done() }, time.Second, 10*time.Millisecond)
+		return d
+	}
+	assert.Nil(t, os.WriteFile(file, []byte("go\tto move\n"), 0o644))
+	d := newDict()
+	assert.Len(t, d.Search("move", 10), 1)
+
+	// the keys are the same, but the definition is changed
+	assert.Nil(t, os.WriteFile(file, []byte("go\tto walk\n"), 0o644))
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(file, later, later))
+	d = newDict()
+	assert.Empty(t, d.Search("move", 10))
+	assert.Len(t, d.Search("walk", 10), 1)
+
+	// the dictionaries of the same name in different directories
+	other := &MdxDict{MdxFile: filepath.Join(t.TempDir(), "team")}
+	assert.Equal(t, d.Name(), other.Name())
+	assert.NotEqual(t, d.indexFile(), other.indexFile())
+}
@@ -0,0 +1,69 @@
+package sources
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DictNames splits a comma-separated list of dictionary names, such as "oald9,ldoce".
+func DictNames(names string) []string {
+	var res []string
+	for _, n := range strings.Split(names, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// Is reports whether name is the Name or the Alias of d, case-insensitively.
+func (d *MdxDict) Is(name string) bool {
+	return strings.EqualFold(name, d.Name()) || (d.Alias != "" && strings.EqualFold(name, d.Alias))
+}
+
+// Select returns the dictionaries named names (their Names or Aliases), in the order of names.
+// All of g is returned if names is empty, and the unknown names are skipped.
+func (g *Dicts) Select(names []string) *Dicts {
+	if len(names) == 0 {
+		return g
+	}
+	res := Dicts{}
+	for _, n := range names {
+		found := false
+		for _, d := range *g {
+			if d.Is(n) {
+				found = true
+				if !res.contains(d) {
+					res = append(res, d)
+				}
+			}
+		}
+		if !found {
+			log.Warnf("no dict named %q", n)
+		}
+	}
+	return &res
+}
+
+func (g *Dicts) contains(d *MdxDict) bool {
+	for _, x := range *g {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+// selector is implemented by sources consisting of several dictionaries, such as *Local,
+// which can be narrowed down to some of them.
+type selector interface {
+	Select(names []string) Source
+}
+
+// Select returns the source of the dictionaries named names only, see Dicts.Select.
+func (l *Local) Select(names []string) Source {
+	res := *l
+	res.Dicts = l.Dicts.Select(names)
+	return &res
+}
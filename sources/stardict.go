@@ -0,0 +1,32 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+	"github.com/ChaosNyaruko/ondict/stardict"
+)
+
+func (d *MdxDict) loadStarDict() (Dict, error) {
+	dict, err := stardict.Open(d.MdxFile)
+	if err != nil {
+		return nil, fmt.Errorf("load stardict %v err: %w", d.MdxFile, err)
+	}
+	return dict, nil
+}
+
+// starDictResource reads path in the "res" directory next to the files of a StarDict dictionary.
+func (d *MdxDict) starDictResource(path string) ([]byte, error) {
+	name := filepath.FromSlash(strings.TrimLeft(strings.ReplaceAll(path, `\`, "/"), "/"))
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("%w: illegal path %q", decoder.ErrNotFound, path)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(d.MdxFile), "res", name))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", decoder.ErrNotFound, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,41 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ChaosNyaruko/ondict/decoder"
+	"github.com/ChaosNyaruko/ondict/export"
+)
+
+func Test_RegisterStarDict(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "sd")
+	assert.Nil(t, export.WriteStarDict(base, decoder.Info{Title: "Star", Format: "Html"}, []export.Entry{
+		{Key: "doctor", Definition: `<b>doctor</b> <img src="img/doctor.png">`},
+		{Key: "doc", Link: "doctor"},
+	}))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "res", "img"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "res", "img", "doctor.png"), []byte("png"), 0o644))
+
+	d := &MdxDict{MdxFile: base, Format: "StarDict"}
+	assert.Nil(t, d.Register(true, false))
+	assert.Equal(t, []string{`<b>doctor</b> <img src="img/doctor.png">`}, d.Get("doc"))
+	info := d.Info()
+	assert.Equal(t, "sd", info.Name)
+	assert.Equal(t, 2, info.Entries)
+	assert.Nil(t, info.MDX)
+	assert.Equal(t, "Star", info.StarDict.BookName)
+
+	g := &Dicts{d}
+	data, err := g.Resource("sd", "/img/doctor.png")
+	assert.Nil(t, err)
+	assert.Equal(t, "png", string(data))
+	_, err = g.Resource("sd", "../sd.ifo")
+	assert.ErrorIs(t, err, decoder.ErrNotFound)
+
+	assert.NotNil(t, (&MdxDict{MdxFile: base, Format: "unknown"}).Register(true, false))
+}
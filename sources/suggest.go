@@ -0,0 +1,202 @@
+package sources
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The kinds of the suggestions, from the best to the worst.
+const (
+	SuggestExact      = "exact"       // the word itself
+	SuggestCaseFolded = "case-folded" // the word in other cases, e.g. "August" for "august"
+	SuggestLemma      = "lemma"       // a lemma of the word, e.g. "go" for "went", see Lemmas
+	SuggestSubstring  = "substring"   // a part of the word, e.g. "error budget" for "what is the error budget"
+	SuggestFuzzy      = "fuzzy"       // a word close to it, e.g. "doctor" for "docter", see BKTree
+)
+
+var suggestRanks = map[string]int{SuggestExact: 0, SuggestCaseFolded: 1, SuggestLemma: 2, SuggestSubstring: 3, SuggestFuzzy: 4}
+
+// Suggestion is a headword suggested for a query.
+type Suggestion struct {
+	Word string `json:"word"`
+	Kind string `json:"kind"`
+	// Distance is the edit distance between the word and the query.
+	Distance int `json:"distance"`
+	// Freq is the word frequency, see NewBKTree.
+	Freq int `json:"freq,omitempty"`
+	// Dicts are the names of the dictionaries which have the word.
+	Dicts []string `json:"dicts"`
+}
+
+// Matched reports whether s is the query itself, in any case.
+func (s Suggestion) Matched() bool {
+	return s.Kind == SuggestExact || s.Kind == SuggestCaseFolded
+}
+
+func sortSuggestions(res []Suggestion) {
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if suggestRanks[a.Kind] != suggestRanks[b.Kind] {
+			return suggestRanks[a.Kind] < suggestRanks[b.Kind]
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.Freq != b.Freq {
+			return a.Freq > b.Freq
+		}
+		return a.Word < b.Word
+	})
+}
+
+// Suggest returns at most n headwords of d for word, ranked by their kinds, the distances and the frequencies.
+// Only the keys are looked up, no records are read. The fuzzy ones are only suggested for the missing words,
+// unless the searcher is a BKTree, and none until the BKTree is built in the background, see fuzzyTree.
+func (d *MdxDict) Suggest(word string, n int) []Suggestion {
+	d.reload()
+	d.mu.RLock()
+	searcher := d.searcher
+	d.mu.RUnlock()
+	keys := d.keyIndex()
+	word = strings.TrimSpace(word)
+	lower := strings.ToLower(word)
+	dist := newDistancer(lower)
+	freqs := wordFrequencies()
+	var res []Suggestion
+	seen := make(map[string]bool)
+	add := func(w string, kind string) {
+		if seen[w] {
+			return
+		}
+		seen[w] = true
+		lw := strings.ToLower(w)
+		res = append(res, Suggestion{Word: w, Kind: kind, Distance: dist.distance(lw), Freq: freqs[lw], Dicts: []string{d.Name()}})
+	}
+
+	for _, k := range keys.Lookup(word) {
+		if k == word {
+			add(k, SuggestExact)
+		} else {
+			add(k, SuggestCaseFolded)
+		}
+	}
+	found := len(res) > 0
+	for _, lemma := range Lemmas(lower) {
+		for _, k := range keys.Lookup(lemma) {
+			add(k, SuggestLemma)
+		}
+	}
+	bk, fuzzy := searcher.(*BKTree)
+	if !fuzzy {
+		for _, part := range phrases(lower) {
+			for _, k := range keys.Lookup(part) {
+				add(k, SuggestSubstring)
+			}
+		}
+		if !found {
+			// the fuzzy suggestions are only for the missing words, to save the memory of BKTree
+			bk = d.fuzzyTree()
+		}
+	}
+	if bk != nil {
+		for _, c := range bk.Candidates(lower) {
+			if c.Distance > 0 {
+				for _, k := range bk.keys(c.Word) {
+					add(k, SuggestFuzzy)
+				}
+			}
+		}
+	}
+	sortSuggestions(res)
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// phrases returns the phrases of the consecutive words in s, except s itself,
+// e.g. "error budget", "error" and "budget" in "the error budget".
+func phrases(s string) []string {
+	words := strings.Fields(s)
+	var res []string
+	for i := range words {
+		for j := i + 1; j <= len(words); j++ {
+			if i == 0 && j == len(words) {
+				continue
+			}
+			res = append(res, strings.Join(words[i:j], " "))
+		}
+	}
+	return res
+}
+
+// fuzzyBuild is a BKTree being built in the background, see fuzzyTree.
+type fuzzyBuild struct {
+	tree *BKTree // nil until it's built, owned by MdxDict.mu
+}
+
+// fuzzyTree returns the BKTree of d for the fuzzy suggestions if the searcher isn't one.
+// It's built in the background on the first call, and nil is returned until it's ready.
+func (d *MdxDict) fuzzyTree() *BKTree {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fuzzy == nil {
+		max := FuzzyDistance
+		if max <= 0 {
+			max = 2
+		}
+		d.fuzzy = &fuzzyBuild{}
+		go d.buildFuzzyTree(d.fuzzy, max)
+	}
+	return d.fuzzy.tree
+}
+
+func (d *MdxDict) buildFuzzyTree(b *fuzzyBuild, max int) {
+	log.Debugf("build the BKTree of %v for the suggestions", d.MdxFile)
+	tree := NewBKTree(d.MdxDict, max)
+	d.mu.Lock()
+	b.tree = tree
+	d.mu.Unlock()
+}
+
+// Suggest returns at most n headwords of all the dictionaries of g for word, see MdxDict.Suggest.
+func (g *Dicts) Suggest(word string, n int) []Suggestion {
+	res := []Suggestion{}
+	index := make(map[string]int) // word -> index in res
+	for _, d := range *g {
+		for _, s := range d.Suggest(word, n) {
+			i, ok := index[s.Word]
+			if !ok {
+				index[s.Word] = len(res)
+				res = append(res, s)
+				continue
+			}
+			if suggestRanks[s.Kind] < suggestRanks[res[i].Kind] {
+				res[i].Kind = s.Kind
+			}
+			res[i].Dicts = append(res[i].Dicts, s.Dicts...)
+		}
+	}
+	sortSuggestions(res)
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// didYouMean returns the HTML of the suggestions, it's empty if the word is found.
+func didYouMean(suggestions []Suggestion) string {
+	if len(suggestions) == 0 || suggestions[0].Matched() {
+		return ""
+	}
+	var links []string
+	for _, s := range suggestions {
+		links = append(links, fmt.Sprintf(`<a href="/dict?query=%s&engine=mdx&format=html">%s</a>`, url.QueryEscape(s.Word), html.EscapeString(s.Word)))
+	}
+	return fmt.Sprintf(`<div class="ondict-suggestions">Did you mean: %s</div>`, strings.Join(links, ", "))
+}
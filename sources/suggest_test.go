@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Suggest(t *testing.T) {
+	old := freqs
+	defer func() { freqs = old }()
+	freqOnce.Do(func() {})
+	freqs = map[string]int{"doctor": 10}
+
+	newDict := func(name string, fzf bool, dict Map) *MdxDict {
+		d := &MdxDict{MdxFile: "/dicts/" + name, MdxDict: dict, fzf: fzf}
+		d.searcher = d.newSearcher()
+		return d
+	}
+	words := Map{"doctor": "1", "docker": "2", "August": "3", "error budget": "4", "error": "5", "go": "6", "budget": "7"}
+	for _, fzf := range []bool{true, false} {
+		d := newDict("a", fzf, words)
+		assert.Empty(t, d.Suggest("docter", 5), "no fuzzy suggestions until the BKTree is built")
+		assert.Eventually(t, func() bool { return d.fuzzyTree() != nil }, time.Second, 10*time.Millisecond)
+		s := d.Suggest("docter", 5)
+		assert.Equal(t, []Suggestion{
+			{Word: "doctor", Kind: SuggestFuzzy, Distance: 1, Freq: 10, Dicts: []string{"a"}},
+			{Word: "docker", Kind: SuggestFuzzy, Distance: 1, Dicts: []string{"a"}},
+		}, s)
+		assert.False(t, s[0].Matched())
+
+		s = d.Suggest("august", 5)
+		assert.Equal(t, SuggestCaseFolded, s[0].Kind)
+		assert.True(t, s[0].Matched())
+		assert.Equal(t, SuggestLemma, d.Suggest("went", 5)[0].Kind)
+		assert.Equal(t, "go", d.Suggest("went", 5)[0].Word)
+		assert.Len(t, d.Suggest("docter", 1), 1)
+	}
+
+	d := newDict("a", false, words)
+	assert.Equal(t, SuggestExact, d.Suggest("doctor", 3)[0].Kind)
+	assert.Nil(t, d.fuzzy, "no BKTree is built for the found words")
+	s := d.Suggest("the error budget", 3)
+	assert.Equal(t, []string{"error budget", "budget", "error"}, []string{s[0].Word, s[1].Word, s[2].Word})
+	assert.Equal(t, SuggestSubstring, s[0].Kind)
+
+	FuzzyDistance = 2
+	defer func() { FuzzyDistance = 0 }()
+	b := newDict("b", false, Map{"docter": "a typo", "doctor": "1"})
+	assert.IsType(t, &BKTree{}, b.searcher)
+	g := &Dicts{d, b}
+	assert.Eventually(t, func() bool { return d.fuzzyTree() != nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []Suggestion{
+		{Word: "docter", Kind: SuggestExact, Dicts: []string{"b"}},
+		{Word: "doctor", Kind: SuggestFuzzy, Distance: 1, Freq: 10, Dicts: []string{"a", "b"}},
+	}, g.Suggest("docter", 2))
+
+	assert.Equal(t, "", didYouMean(g.Suggest("docter", 2)))
+	assert.Equal(t, `<div class="ondict-suggestions">Did you mean: <a href="/dict?query=doctor&engine=mdx&format=html">doctor</a>, `+
+		`<a href="/dict?query=docker&engine=mdx&format=html">docker</a></div>`, didYouMean(d.Suggest("doctr", 2)))
+	assert.Contains(t, (&Dicts{d}).Query("doctr", "html"), "Did you mean")
+}
@@ -0,0 +1,181 @@
+package stardict
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzip header flags, see RFC 1952
+const (
+	flagHCRC    = 1 << 1
+	flagExtra   = 1 << 2
+	flagName    = 1 << 3
+	flagComment = 1 << 4
+)
+
+// dictzip is the random access reader of a dictzip file, i.e. a gzip file whose deflate stream is
+// flushed at every chunkLen bytes of the uncompressed data, with the compressed chunk sizes
+// recorded in the "RA" subfield of the gzip header. See dictzip(1).
+type dictzip struct {
+	r        io.ReaderAt
+	chunkLen int
+	offsets  []int64 // offsets[i] is where the ith chunk starts in the file, with the end of the last chunk appended
+}
+
+// openDictzip opens a .dz file, it's decompressed into memory as a whole if it's a plain gzip file.
+func openDictzip(f *os.File) (io.ReaderAt, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	dz, err := parseDictzip(f, fi.Size())
+	if err == nil {
+		return dz, nil
+	}
+	if !errors.Is(err, errNoRA) {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	data, err := io.ReadAll(z)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+var errNoRA = errors.New("no RA subfield in the gzip header")
+
+// parseDictzip parses the header of a dictzip file of size bytes.
+func parseDictzip(r io.ReaderAt, size int64) (*dictzip, error) {
+	var fixed [10]byte
+	if _, err := r.ReadAt(fixed[:], 0); err != nil {
+		return nil, fmt.Errorf("read gzip header err: %v", err)
+	}
+	if fixed[0] != 0x1f || fixed[1] != 0x8b || fixed[2] != 8 {
+		return nil, fmt.Errorf("not a gzip file")
+	}
+	flags := fixed[3]
+	if flags&flagExtra == 0 {
+		return nil, errNoRA
+	}
+	pos := int64(len(fixed))
+	var xlen [2]byte
+	if _, err := r.ReadAt(xlen[:], pos); err != nil {
+		return nil, fmt.Errorf("read gzip extra field err: %v", err)
+	}
+	pos += 2
+	extra := make([]byte, binary.LittleEndian.Uint16(xlen[:]))
+	if _, err := r.ReadAt(extra, pos); err != nil {
+		return nil, fmt.Errorf("read gzip extra field err: %v", err)
+	}
+	pos += int64(len(extra))
+
+	var dz *dictzip
+	for len(extra) >= 4 {
+		id, n := string(extra[:2]), int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+n > len(extra) {
+			return nil, fmt.Errorf("bad gzip extra subfield %q", id)
+		}
+		data := extra[4 : 4+n]
+		extra = extra[4+n:]
+		if id != "RA" {
+			continue
+		}
+		// version, chunk length, chunk count, then the compressed size of each chunk
+		if len(data) < 6 || binary.LittleEndian.Uint16(data) != 1 {
+			return nil, fmt.Errorf("unsupported RA subfield")
+		}
+		chunkLen, count := int(binary.LittleEndian.Uint16(data[2:])), int(binary.LittleEndian.Uint16(data[4:]))
+		if chunkLen == 0 || count == 0 {
+			return nil, fmt.Errorf("bad RA subfield, %d chunks of %d bytes", count, chunkLen)
+		}
+		if len(data) < 6+2*count {
+			return nil, fmt.Errorf("bad RA subfield, %d chunks in %d bytes", count, len(data))
+		}
+		dz = &dictzip{r: r, chunkLen: chunkLen}
+		dz.offsets = make([]int64, count+1)
+		for i := 0; i < count; i++ {
+			dz.offsets[i+1] = dz.offsets[i] + int64(binary.LittleEndian.Uint16(data[6+2*i:]))
+		}
+	}
+	if dz == nil {
+		return nil, errNoRA
+	}
+
+	// skip the null-terminated file name and comment
+	for _, flag := range []byte{flagName, flagComment} {
+		if flags&flag == 0 {
+			continue
+		}
+		var c [1]byte
+		for {
+			if _, err := r.ReadAt(c[:], pos); err != nil {
+				return nil, fmt.Errorf("read gzip header err: %v", err)
+			}
+			pos++
+			if c[0] == 0 {
+				break
+			}
+		}
+	}
+	if flags&flagHCRC != 0 {
+		pos += 2
+	}
+	for i := range dz.offsets {
+		dz.offsets[i] += pos
+	}
+	if end := dz.offsets[len(dz.offsets)-1]; end > size {
+		return nil, fmt.Errorf("bad RA subfield, the chunks end at %d beyond the file size %d", end, size)
+	}
+	return dz, nil
+}
+
+// chunk returns the ith uncompressed chunk.
+func (dz *dictzip) chunk(i int) ([]byte, error) {
+	start, end := dz.offsets[i], dz.offsets[i+1]
+	z := flate.NewReader(io.NewSectionReader(dz.r, start, end-start))
+	defer z.Close()
+	buf := make([]byte, dz.chunkLen)
+	n, err := io.ReadFull(z, buf)
+	// the chunks are ended with a sync flush instead of a final block, except the last one
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decompress chunk %d err: %v", i, err)
+	}
+	return buf[:n], nil
+}
+
+func (dz *dictzip) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		i := int((off + int64(n)) / int64(dz.chunkLen))
+		if i >= len(dz.offsets)-1 {
+			return n, io.EOF
+		}
+		data, err := dz.chunk(i)
+		if err != nil {
+			return n, err
+		}
+		skip := int(off + int64(n) - int64(i)*int64(dz.chunkLen))
+		if skip >= len(data) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[skip:])
+	}
+	return n, nil
+}
@@ -0,0 +1,106 @@
+package stardict
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// field is a piece of a definition, typ is one of the type identifiers in the StarDict format,
+// such as 'm' for pure text, 'h' for HTML and 'W' for WAV sound.
+type field struct {
+	typ  byte
+	data []byte
+}
+
+// isText reports whether the fields of type typ are null-terminated strings,
+// the others (the upper case ones) are binary data prefixed with their sizes.
+func isText(typ byte) bool {
+	return 'a' <= typ && typ <= 'z'
+}
+
+// parseFields splits the data of a definition into fields. If sametypesequence is set,
+// the types are omitted in the data, as well as the terminator or size of the last field.
+func parseFields(data []byte, sametypesequence string) ([]field, error) {
+	var fields []field
+	if sametypesequence != "" {
+		for i := 0; i < len(sametypesequence); i++ {
+			typ := sametypesequence[i]
+			if i == len(sametypesequence)-1 {
+				fields = append(fields, field{typ, data})
+				break
+			}
+			f, rest, err := readField(typ, data)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+			data = rest
+		}
+		return fields, nil
+	}
+	for len(data) > 0 {
+		f, rest, err := readField(data[0], data[1:])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+		data = rest
+	}
+	return fields, nil
+}
+
+func readField(typ byte, data []byte) (field, []byte, error) {
+	if isText(typ) {
+		i := bytes.IndexByte(data, 0)
+		if i < 0 {
+			// tolerate the missing terminator of the last field
+			return field{typ, data}, nil, nil
+		}
+		return field{typ, data[:i]}, data[i+1:], nil
+	}
+	if len(data) < 4 {
+		return field{}, nil, fmt.Errorf("no size of the field %q", typ)
+	}
+	size := binary.BigEndian.Uint32(data)
+	if uint64(len(data)-4) < uint64(size) {
+		return field{}, nil, fmt.Errorf("field %q of %d bytes is out of the data", typ, size)
+	}
+	return field{typ, data[4 : 4+size]}, data[4+size:], nil
+}
+
+// toHTML renders the fields to HTML, the binary ones, such as sounds and pictures, are dropped.
+func toHTML(fields []field) string {
+	var res []string
+	for _, f := range fields {
+		s := string(f.data)
+		switch f.typ {
+		case 'h', 'g', 'x':
+			// HTML, Pango markup and XDXF are all close enough to HTML
+			res = append(res, s)
+		case 't':
+			res = append(res, `<span class="phonetic">[`+html.EscapeString(s)+`]</span>`)
+		case 'y':
+			res = append(res, `<span class="yinbiao">`+html.EscapeString(s)+`</span>`)
+		case 'm', 'l', 'k', 'w', 'n':
+			res = append(res, textToHTML(s))
+		case 'r':
+			// the resources are listed as "img:pic/a.jpg", one per line
+			for _, r := range strings.Split(s, "\n") {
+				kind, path, ok := strings.Cut(strings.TrimSpace(r), ":")
+				if ok && kind == "img" {
+					res = append(res, `<img src="`+html.EscapeString(path)+`">`)
+				}
+			}
+		}
+	}
+	return strings.Join(res, "\n")
+}
+
+// textToHTML escapes s, and keeps its line breaks.
+func textToHTML(s string) string {
+	s = strings.ReplaceAll(strings.TrimRight(s, "\r\n"), "\r\n", "\n")
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
@@ -0,0 +1,307 @@
+// Package stardict reads StarDict dictionaries, i.e. the .ifo, .idx(.gz), .dict(.dz) and the optional .syn files.
+package stardict
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Info is the metadata in the .ifo file.
+type Info struct {
+	Version          string `json:"version"`
+	BookName         string `json:"bookname"`
+	WordCount        int    `json:"wordcount"`
+	SynWordCount     int    `json:"synwordcount"`
+	IdxFileSize      int64  `json:"idxfilesize"`
+	IdxOffsetBits    int    `json:"idxoffsetbits"`
+	Author           string `json:"author,omitempty"`
+	Email            string `json:"email,omitempty"`
+	Website          string `json:"website,omitempty"`
+	Description      string `json:"description,omitempty"`
+	Date             string `json:"date,omitempty"`
+	SameTypeSequence string `json:"sametypesequence,omitempty"`
+}
+
+// word is an entry in the .idx file.
+type word struct {
+	word   string
+	offset uint64
+	size   uint32
+}
+
+// Dict is an opened StarDict dictionary, the index is kept in memory and
+// the definitions are read from the .dict(.dz) file on demand.
+type Dict struct {
+	info  Info
+	words []word
+	syns  map[string][]int // synonym -> indexes of words
+	keys  map[string][]int // the lowercase word/synonym -> indexes of words
+	data  io.ReaderAt
+	file  *os.File
+}
+
+// Open opens the StarDict dictionary whose files are base.ifo, base.idx, etc.
+func Open(base string) (*Dict, error) {
+	base = strings.TrimSuffix(base, ".ifo")
+	info, err := readIfo(base + ".ifo")
+	if err != nil {
+		return nil, err
+	}
+	d := &Dict{info: info, keys: make(map[string][]int), syns: make(map[string][]int)}
+	if err := d.readIdx(base); err != nil {
+		return nil, err
+	}
+	if err := d.readSyn(base + ".syn"); err != nil {
+		return nil, err
+	}
+	if err := d.openData(base); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func readIfo(name string) (Info, error) {
+	var info Info
+	f, err := os.Open(name)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !s.Scan() || strings.TrimPrefix(strings.TrimSpace(s.Text()), "\ufeff") != "StarDict's dict ifo file" {
+		return info, fmt.Errorf("%v is not a StarDict ifo file", name)
+	}
+	for s.Scan() {
+		k, v, ok := strings.Cut(strings.TrimRight(s.Text(), "\r"), "=")
+		if !ok {
+			continue
+		}
+		var err error
+		switch k {
+		case "version":
+			info.Version = v
+		case "bookname":
+			info.BookName = v
+		case "wordcount":
+			info.WordCount, err = strconv.Atoi(v)
+		case "synwordcount":
+			info.SynWordCount, err = strconv.Atoi(v)
+		case "idxfilesize":
+			info.IdxFileSize, err = strconv.ParseInt(v, 10, 64)
+		case "idxoffsetbits":
+			info.IdxOffsetBits, err = strconv.Atoi(v)
+		case "author":
+			info.Author = v
+		case "email":
+			info.Email = v
+		case "website":
+			info.Website = v
+		case "description":
+			info.Description = v
+		case "date":
+			info.Date = v
+		case "sametypesequence":
+			info.SameTypeSequence = v
+		}
+		if err != nil {
+			return info, fmt.Errorf("bad %v in %v: %v", k, name, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return info, fmt.Errorf("read %v err: %v", name, err)
+	}
+	if info.IdxOffsetBits == 0 {
+		info.IdxOffsetBits = 32
+	}
+	if info.IdxOffsetBits != 32 && info.IdxOffsetBits != 64 {
+		return info, fmt.Errorf("unsupported idxoffsetbits %d in %v", info.IdxOffsetBits, name)
+	}
+	return info, nil
+}
+
+// readFile reads name, or the gzipped name.gz if name doesn't exist.
+func readFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if !errors.Is(err, os.ErrNotExist) {
+		return data, err
+	}
+	f, err := os.Open(name + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %v.gz err: %v", name, err)
+	}
+	defer z.Close()
+	return io.ReadAll(z)
+}
+
+func (d *Dict) readIdx(base string) error {
+	data, err := readFile(base + ".idx")
+	if err != nil {
+		return err
+	}
+	offsetSize := d.info.IdxOffsetBits / 8
+	d.words = make([]word, 0, d.info.WordCount)
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, 0)
+		if i < 0 || len(data) < i+1+offsetSize+4 {
+			return fmt.Errorf("bad entry %d in %v.idx", len(d.words), base)
+		}
+		w := word{word: string(data[:i])}
+		data = data[i+1:]
+		if offsetSize == 8 {
+			w.offset = binary.BigEndian.Uint64(data)
+		} else {
+			w.offset = uint64(binary.BigEndian.Uint32(data))
+		}
+		w.size = binary.BigEndian.Uint32(data[offsetSize:])
+		data = data[offsetSize+4:]
+		key := strings.ToLower(w.word)
+		d.keys[key] = append(d.keys[key], len(d.words))
+		d.words = append(d.words, w)
+	}
+	if d.info.WordCount != 0 && d.info.WordCount != len(d.words) {
+		log.Warnf("%v.idx has %d words, but wordcount is %d", base, len(d.words), d.info.WordCount)
+	}
+	return nil
+}
+
+func (d *Dict) readSyn(name string) error {
+	data, err := readFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for n := 0; len(data) > 0; n++ {
+		i := bytes.IndexByte(data, 0)
+		if i < 0 || len(data) < i+5 {
+			return fmt.Errorf("bad entry %d in %v", n, name)
+		}
+		syn, index := string(data[:i]), int(binary.BigEndian.Uint32(data[i+1:]))
+		data = data[i+5:]
+		if index >= len(d.words) {
+			log.Warnf("synonym %q in %v refers to word %d out of %d words", syn, name, index, len(d.words))
+			continue
+		}
+		d.syns[syn] = append(d.syns[syn], index)
+		key := strings.ToLower(syn)
+		d.keys[key] = append(d.keys[key], index)
+	}
+	return nil
+}
+
+func (d *Dict) openData(base string) error {
+	f, err := os.Open(base + ".dict.dz")
+	if err == nil {
+		d.file = f
+		d.data, err = openDictzip(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("open %v.dict.dz err: %v", base, err)
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f, err = os.Open(base + ".dict")
+	if err != nil {
+		return err
+	}
+	d.file, d.data = f, f
+	return nil
+}
+
+// Close closes the .dict(.dz) file.
+func (d *Dict) Close() error {
+	return d.file.Close()
+}
+
+// Info returns the metadata of d.
+func (d *Dict) Info() Info {
+	return d.info
+}
+
+// Keys returns all the words and synonyms.
+func (d *Dict) Keys() []string {
+	keys := make([]string, 0, len(d.words)+len(d.syns))
+	for _, w := range d.words {
+		keys = append(keys, w.word)
+	}
+	for syn := range d.syns {
+		keys = append(keys, syn)
+	}
+	return keys
+}
+
+// Lookup returns the HTML of all the definitions of word, or its synonyms, case-insensitively.
+// The definitions of the words exactly equal to word come first.
+func (d *Dict) Lookup(word string) ([]string, error) {
+	indexes := append([]int(nil), d.keys[strings.ToLower(word)]...)
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return d.words[indexes[i]].word == word && d.words[indexes[j]].word != word
+	})
+	var res []string
+	seen := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		def, err := d.definition(i)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, def)
+	}
+	return res, nil
+}
+
+// Get returns the first definition of word, errors are logged.
+func (d *Dict) Get(word string) string {
+	defs := d.GetAll(word)
+	if len(defs) == 0 {
+		return ""
+	}
+	return defs[0]
+}
+
+// GetAll returns all the definitions of word, errors are logged.
+func (d *Dict) GetAll(word string) []string {
+	defs, err := d.Lookup(word)
+	if err != nil {
+		log.Warnf("lookup %q in %v err: %v", word, d.info.BookName, err)
+	}
+	return defs
+}
+
+// definition reads the ith definition in the .dict file and renders it to HTML.
+func (d *Dict) definition(i int) (string, error) {
+	w := d.words[i]
+	data := make([]byte, w.size)
+	if n, err := d.data.ReadAt(data, int64(w.offset)); n < len(data) {
+		return "", fmt.Errorf("read the definition of %q err: %v", w.word, err)
+	}
+	fields, err := parseFields(data, d.info.SameTypeSequence)
+	if err != nil {
+		return "", fmt.Errorf("parse the definition of %q err: %v", w.word, err)
+	}
+	return toHTML(fields), nil
+}
@@ -0,0 +1,205 @@
+package stardict
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testWord struct {
+	word string
+	data string
+}
+
+// writeStarDict writes the words and the synonyms (synonym -> index) as a StarDict dictionary in a temporary directory,
+// the .dict file is dictzipped with chunkLen if it's not 0.
+func writeStarDict(t *testing.T, sametypesequence string, words []testWord, syns map[string]int, chunkLen int) string {
+	t.Helper()
+	base := filepath.Join(t.TempDir(), "test")
+	var dict, idx, syn bytes.Buffer
+	for _, w := range words {
+		idx.WriteString(w.word + "\x00")
+		binary.Write(&idx, binary.BigEndian, uint32(dict.Len()))
+		binary.Write(&idx, binary.BigEndian, uint32(len(w.data)))
+		dict.WriteString(w.data)
+	}
+	for s, i := range syns {
+		syn.WriteString(s + "\x00")
+		binary.Write(&syn, binary.BigEndian, uint32(i))
+	}
+	ifo := fmt.Sprintf("StarDict's dict ifo file\nversion=3.0.0\nbookname=Test\nwordcount=%d\nsynwordcount=%d\nidxfilesize=%d\n",
+		len(words), len(syns), idx.Len())
+	if sametypesequence != "" {
+		ifo += "sametypesequence=" + sametypesequence + "\n"
+	}
+	assert.Nil(t, os.WriteFile(base+".ifo", []byte(ifo), 0o644))
+	assert.Nil(t, os.WriteFile(base+".idx", idx.Bytes(), 0o644))
+	if len(syns) > 0 {
+		assert.Nil(t, os.WriteFile(base+".syn", syn.Bytes(), 0o644))
+	}
+	if chunkLen == 0 {
+		assert.Nil(t, os.WriteFile(base+".dict", dict.Bytes(), 0o644))
+	} else {
+		assert.Nil(t, os.WriteFile(base+".dict.dz", dictzipped(t, dict.Bytes(), chunkLen), 0o644))
+	}
+	return base
+}
+
+// dictzipped compresses data in the dictzip format, each chunk is compressed independently.
+func dictzipped(t *testing.T, data []byte, chunkLen int) []byte {
+	var chunks bytes.Buffer
+	var sizes []uint16
+	for i := 0; i < len(data); i += chunkLen {
+		end := i + chunkLen
+		if end > len(data) {
+			end = len(data)
+		}
+		before := chunks.Len()
+		z, err := flate.NewWriter(&chunks, flate.BestCompression)
+		assert.Nil(t, err)
+		z.Write(data[i:end])
+		if end == len(data) {
+			assert.Nil(t, z.Close())
+		} else {
+			assert.Nil(t, z.Flush())
+		}
+		sizes = append(sizes, uint16(chunks.Len()-before))
+	}
+	var ra bytes.Buffer
+	ra.WriteString("RA")
+	binary.Write(&ra, binary.LittleEndian, uint16(6+2*len(sizes)))
+	for _, n := range append([]uint16{1, uint16(chunkLen), uint16(len(sizes))}, sizes...) {
+		binary.Write(&ra, binary.LittleEndian, n)
+	}
+	var out bytes.Buffer
+	out.Write([]byte{0x1f, 0x8b, 8, flagExtra | flagName, 0, 0, 0, 0, 2, 3})
+	binary.Write(&out, binary.LittleEndian, uint16(ra.Len()))
+	out.Write(ra.Bytes())
+	out.WriteString("test.dict\x00")
+	out.Write(chunks.Bytes())
+	binary.Write(&out, binary.LittleEndian, crc32.ChecksumIEEE(data))
+	binary.Write(&out, binary.LittleEndian, uint32(len(data)))
+	return out.Bytes()
+}
+
+func Test_Open(t *testing.T) {
+	words := []testWord{
+		{"apple", "a fruit\nred or green"},
+		{"Apple", "a company"},
+		{"banana", "<b>yellow</b> & long"},
+		{"tormentor", strings.Repeat("someone who torments ", 10)},
+	}
+	for _, chunkLen := range []int{0, 7, 4096} {
+		t.Run(fmt.Sprintf("chunk %d", chunkLen), func(t *testing.T) {
+			base := writeStarDict(t, "m", words, map[string]int{"tormenter": 3, "APPLES": 0}, chunkLen)
+			d, err := Open(base + ".ifo")
+			assert.Nil(t, err)
+			defer d.Close()
+			assert.Equal(t, "Test", d.Info().BookName)
+			assert.Equal(t, 4, d.Info().WordCount)
+			assert.ElementsMatch(t, []string{"apple", "Apple", "banana", "tormentor", "tormenter", "APPLES"}, d.Keys())
+
+			assert.Equal(t, []string{"a company", "a fruit<br>red or green"}, d.GetAll("Apple"))
+			assert.Equal(t, "a fruit<br>red or green", d.Get("apple"))
+			assert.Equal(t, "a fruit<br>red or green", d.Get("apples"))
+			assert.Equal(t, "&lt;b&gt;yellow&lt;/b&gt; &amp; long", d.Get("BANANA"))
+			assert.Equal(t, words[3].data, d.Get("tormenter"))
+			assert.Equal(t, "", d.Get("nothing"))
+		})
+	}
+}
+
+func Test_Fields(t *testing.T) {
+	size := func(s string) string {
+		var b bytes.Buffer
+		binary.Write(&b, binary.BigEndian, uint32(len(s)))
+		return b.String() + s
+	}
+	for _, c := range []struct {
+		name             string
+		sametypesequence string
+		data             string
+		want             string
+	}{
+		{"html", "h", "<i>x</i>", "<i>x</i>"},
+		{"phonetic and text", "tm", "ˈæpl\x00a fruit", "<span class=\"phonetic\">[ˈæpl]</span>\na fruit"},
+		{"sound first", "Wh", size("RIFF") + "<b>x</b>", "<b>x</b>"},
+		{"no sametypesequence", "", "t" + "ˈæpl\x00" + "W" + size("RIFF") + "h<b>x</b>\x00", "<span class=\"phonetic\">[ˈæpl]</span>\n<b>x</b>"},
+		{"resources", "rh", "img:a.png\nsnd:a.wav\x00<b>x</b>", "<img src=\"a.png\">\n<b>x</b>"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			base := writeStarDict(t, c.sametypesequence, []testWord{{"x", c.data}}, nil, 0)
+			d, err := Open(base)
+			assert.Nil(t, err)
+			defer d.Close()
+			defs, err := d.Lookup("x")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{c.want}, defs)
+		})
+	}
+
+	base := writeStarDict(t, "", []testWord{{"x", "W" + size("RIFF")[:6]}}, nil, 0)
+	d, err := Open(base)
+	assert.Nil(t, err)
+	defer d.Close()
+	_, err = d.Lookup("x")
+	assert.NotNil(t, err)
+}
+
+func Test_OpenGzippedIdx(t *testing.T) {
+	base := writeStarDict(t, "m", []testWord{{"a", "letter a"}, {"b", "letter b"}}, nil, 0)
+	idx, err := os.ReadFile(base + ".idx")
+	assert.Nil(t, err)
+	var gz bytes.Buffer
+	z := gzip.NewWriter(&gz)
+	z.Write(idx)
+	assert.Nil(t, z.Close())
+	assert.Nil(t, os.WriteFile(base+".idx.gz", gz.Bytes(), 0o644))
+	assert.Nil(t, os.Remove(base+".idx"))
+
+	d, err := Open(base)
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, "letter b", d.Get("b"))
+}
+
+func Test_OpenBroken(t *testing.T) {
+	base := writeStarDict(t, "m", []testWord{{"a", "letter a"}}, nil, 0)
+	assert.Nil(t, os.WriteFile(base+".idx", []byte("a\x00\x00\x00"), 0o644))
+	_, err := Open(base)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, os.WriteFile(base+".ifo", []byte("not an ifo file\n"), 0o644))
+	_, err = Open(base)
+	assert.NotNil(t, err)
+
+	_, err = Open(filepath.Join(t.TempDir(), "nothing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_OpenBrokenDictzip(t *testing.T) {
+	base := writeStarDict(t, "m", []testWord{{"a", "letter a"}, {"b", "letter b"}}, nil, 4)
+	dz, err := os.ReadFile(base + ".dict.dz")
+	assert.Nil(t, err)
+	// the RA subfield starts at 12: "RA", its length, the version, the chunk length and the chunk count
+	for name, broken := range map[string]func([]byte){
+		"zero chunk length": func(b []byte) { binary.LittleEndian.PutUint16(b[18:], 0) },
+		"zero chunk count":  func(b []byte) { binary.LittleEndian.PutUint16(b[20:], 0) },
+		"chunks beyond EOF": func(b []byte) { binary.LittleEndian.PutUint16(b[22:], 0xffff) },
+	} {
+		b := append([]byte(nil), dz...)
+		broken(b)
+		assert.Nil(t, os.WriteFile(base+".dict.dz", b, 0o644))
+		_, err := Open(base)
+		assert.NotNil(t, err, name)
+	}
+}
@@ -12,7 +12,8 @@ const portal = `
         <h1>
         <form action="/dict?format=html" method="get">
         <label for="word">Query:</label>
-        <input type="text" id="name" name="query" placeholder="doctor" required/><br>
+        <input type="text" id="name" name="query" placeholder="doctor" list="completions" autocomplete="off" required/><br>
+        <datalist id="completions"></datalist>
         <label for="word">Engine:</label>
         <input type="text" id="engine" name="engine" value="mdx" placeholder="mdx"/><br>
         <!-- <label for="word">Format:</label> -->
@@ -23,6 +24,27 @@ const portal = `
         <input type="submit" value="Submit"/>
         </form>
         </h1>
+        <script>
+            // complete the query with the keys of the local dictionaries
+            const input = document.getElementById("name");
+            const completions = document.getElementById("completions");
+            input.addEventListener("input", async () => {
+                if (input.value.trim() === "") {
+                    return;
+                }
+                const q = input.value;
+                const resp = await fetch("/autocomplete?limit=10&q=" + encodeURIComponent(q));
+                if (!resp.ok || input.value !== q) {
+                    return;
+                }
+                const data = await resp.json();
+                completions.replaceChildren(...data.results.map(r => {
+                    const option = document.createElement("option");
+                    option.value = r.searchtext;
+                    return option;
+                }));
+            });
+        </script>
     </body>
 </html>
 `
@@ -42,3 +42,11 @@ func TmpDir() string {
 	}
 	return tmpPath
 }
+
+func FrequencyFile() string {
+	return filepath.Join(ConfigPath(), "frequency.txt")
+}
+
+func LemmaFile() string {
+	return filepath.Join(ConfigPath(), "lemmas.txt")
+}